@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
@@ -57,17 +58,35 @@ func LoadAssets(root string, maxTileIndex int) *Assets {
 }
 
 func loadImage(path string, fallback func() *ebiten.Image) *ebiten.Image {
-	data, err := os.ReadFile(path)
+	img, err := decodeImageFile(path)
 	if err != nil {
-		log.Printf("missing asset %s (%v), using placeholder", path, err)
+		log.Printf("%v, using placeholder", err)
 		return fallback()
 	}
-	img, _, err := image.Decode(bytes.NewReader(data))
+	return ebiten.NewImageFromImage(img)
+}
+
+// loadMask is like loadImage but keeps the decoded pixels on the CPU, for
+// assets that are sampled rather than drawn.
+func loadMask(path string, fallback func() image.Image) image.Image {
+	img, err := decodeImageFile(path)
 	if err != nil {
-		log.Printf("failed to decode %s (%v), using placeholder", path, err)
+		log.Printf("%v, using placeholder", err)
 		return fallback()
 	}
-	return ebiten.NewImageFromImage(img)
+	return img
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("missing asset %s (%v)", path, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s (%v)", path, err)
+	}
+	return img, nil
 }
 
 func makePlaceholderTiles(tileW, tileH, total int) *ebiten.Image {
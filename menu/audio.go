@@ -4,118 +4,387 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/olivierh59500/ym-player/pkg/stsound"
 )
 
+// bytesPerSample is the size of one stereo 16-bit sample frame in the
+// stream handed to the audio context.
+const bytesPerSample = 4
+
 type YMPlayer struct {
-	player       *stsound.StSound
+	player       ymSynth
+	info         SourceInfo
+	data         []byte
+	file         *YMFile
+	frameSamples int64
 	sampleRate   int
 	buffer       []int16
-	mutex        sync.Mutex
-	position     int64
+	stereo       []int16
+	levels       [][ayVoices]int32
+	dsp          outputDSP
 	totalSamples int64
-	loop         bool
-	volume       float64
+	endSample    int64
+
+	// render is held by Read and Seek for the engine, its buffers and the
+	// filters. mutex guards the rest and is never held while rendering,
+	// so the game can fade, mute or ask for the position at any time.
+	// Settings are handed to the engine at the start of the next read.
+	render   sync.Mutex
+	mutex    sync.Mutex
+	position int64
+	loop     bool
+	volume   gainRamp
+	fades    int
+	voices   Voices
+	panning  Panning
+	filter   OutputFilter
+	changed  bool
+	scope    *ScopeRing
+	state    AYState
 }
 
 func NewYMPlayer(data []byte, sampleRate int, loop bool) (*YMPlayer, error) {
-	player := stsound.CreateWithRate(sampleRate)
-	if err := player.LoadMemory(data); err != nil {
-		player.Destroy()
-		return nil, fmt.Errorf("failed to load YM data: %w", err)
+	player, err := newYMEngine(data, sampleRate, loop)
+	if err != nil {
+		return nil, err
 	}
 
-	player.SetLoopMode(loop)
 	info := player.GetInfo()
 	totalSamples := int64(info.MusicTimeInMs) * int64(sampleRate) / 1000
 
-	return &YMPlayer{
-		player:       player,
+	y := &YMPlayer{
+		player: player,
+		info: SourceInfo{
+			Title:    info.SongName,
+			Author:   info.SongAuthor,
+			Comment:  info.SongComment,
+			Format:   info.SongType,
+			Duration: time.Duration(info.MusicTimeInMs) * time.Millisecond,
+		},
+		data:         data,
 		sampleRate:   sampleRate,
 		buffer:       make([]int16, 4096),
+		stereo:       make([]int16, 4096*2),
+		levels:       make([][ayVoices]int32, 4096),
 		totalSamples: totalSamples,
 		loop:         loop,
-		volume:       0.7,
-	}, nil
+		voices:       allVoices,
+		panning:      MonoPanning,
+	}
+	y.volume.set(0.7)
+	if file, err := ParseYMFile(data); err == nil && file.FrameRate > 0 {
+		// Same integer division as the engine's own frame timing.
+		y.file = file
+		y.frameSamples = int64(sampleRate / file.FrameRate)
+		y.endSample = int64(file.Frames) * y.frameSamples
+		if ayEngineSupports(file) {
+			player.Destroy()
+			y.player = y.newAYEngine()
+		}
+	}
+	start, _ := y.loopBounds()
+	y.info.LoopStart = samplesToDuration(start, sampleRate)
+	y.state = y.engineState()
+	return y, nil
+}
+
+// newEngine creates a fresh synth for the tune: the AY emulation when the
+// file has a register stream it understands, stsound otherwise.
+func (y *YMPlayer) newEngine() (ymSynth, error) {
+	if y.file != nil && ayEngineSupports(y.file) {
+		return y.newAYEngine(), nil
+	}
+	return newYMEngine(y.data, y.sampleRate, y.loop)
+}
+
+func (y *YMPlayer) newAYEngine() *ayEngine {
+	e := newAYEngine(y.file, y.sampleRate, y.loop, y.voices)
+	e.SetPanning(y.panning)
+	e.levels = y.levels
+	return e
 }
 
+func newYMEngine(data []byte, sampleRate int, loop bool) (*stsound.StSound, error) {
+	player := stsound.CreateWithRate(sampleRate)
+	if err := player.LoadMemory(data); err != nil {
+		player.Destroy()
+		return nil, fmt.Errorf("failed to load YM data: %w", err)
+	}
+	player.SetLoopMode(loop)
+	return player, nil
+}
+
+// Read fills p with 16-bit stereo samples. When the tune doesn't loop, the
+// last read stops exactly at the end of the final frame and returns io.EOF,
+// so a following tune can start without a gap. It renders through buffers
+// kept in the player and writes straight into p, so the audio thread never
+// allocates.
 func (y *YMPlayer) Read(p []byte) (n int, err error) {
+	y.render.Lock()
+	defer y.render.Unlock()
+
 	y.mutex.Lock()
-	defer y.mutex.Unlock()
+	y.apply()
+	loop, position, scope := y.loop, y.position, y.scope
+	volume, fades := y.volume, y.fades
+	y.mutex.Unlock()
+	if y.player == nil {
+		return 0, io.EOF
+	}
 
-	samplesNeeded := len(p) / 4
-	outBuffer := make([]int16, samplesNeeded*2)
+	samples := len(p) / bytesPerSample
+	if !loop && y.endSample > 0 && position+int64(samples) >= y.endSample {
+		samples = int(max(y.endSample-position, 0))
+		err = io.EOF
+	}
 
-	processed := 0
-	for processed < samplesNeeded {
-		chunkSize := samplesNeeded - processed
-		if chunkSize > len(y.buffer) {
-			chunkSize = len(y.buffer)
+	engine, stereo := y.player.(*ayEngine)
+	stereo = stereo && engine.Stereo()
+	for done := 0; done < samples; {
+		chunk := min(samples-done, len(y.buffer))
+		var more bool
+		if stereo {
+			more = engine.ComputeStereo(y.stereo[:chunk*2], chunk)
+		} else {
+			more = y.player.Compute(y.buffer[:chunk], chunk)
+		}
+		if !more && !loop {
+			// The engine ran out before the end the header gives: the rest
+			// is silence, so the stream still ends where it said it would.
+			clear(p[done*bytesPerSample : samples*bytesPerSample])
+			err = io.EOF
+			break
+		}
+		if stereo {
+			y.dsp.process(y.stereo[:chunk*2], true)
+		} else {
+			y.dsp.process(y.buffer[:chunk], false)
 		}
 
-		if !y.player.Compute(y.buffer[:chunkSize], chunkSize) {
-			if !y.loop {
-				for i := processed * 2; i < len(outBuffer); i++ {
-					outBuffer[i] = 0
+		out := p[done*bytesPerSample : (done+chunk)*bytesPerSample]
+		// The volume only needs working out per sample while it fades.
+		steady := volume.steady()
+		gain := gainFixed(volume.value)
+		for i := 0; i < chunk; i++ {
+			if !steady {
+				gain = gainFixed(volume.next())
+			}
+			var l, r int16
+			if stereo {
+				l, r = applyGain(y.stereo[i*2], gain), applyGain(y.stereo[i*2+1], gain)
+			} else {
+				l = applyGain(y.buffer[i], gain)
+				r = l
+			}
+			out[i*4], out[i*4+1] = byte(l), byte(l>>8)
+			out[i*4+2], out[i*4+3] = byte(r), byte(r>>8)
+			if scope != nil {
+				var levels [ayVoices]int32
+				if engine != nil {
+					levels = y.levels[i]
 				}
-				err = io.EOF
-				break
+				scope.push(int16((int32(l)+int32(r))/2), levels)
 			}
 		}
-
-		for i := 0; i < chunkSize; i++ {
-			sample := int16(float64(y.buffer[i]) * y.volume)
-			outBuffer[(processed+i)*2] = sample
-			outBuffer[(processed+i)*2+1] = sample
-		}
-
-		processed += chunkSize
-		y.position += int64(chunkSize)
+		done += chunk
 	}
 
-	buf := make([]byte, 0, len(outBuffer)*2)
-	for _, sample := range outBuffer {
-		buf = append(buf, byte(sample), byte(sample>>8))
+	// The volume is only written back when it wasn't changed meanwhile.
+	y.mutex.Lock()
+	y.position += int64(samples)
+	if y.fades == fades {
+		y.volume = volume
 	}
+	y.state = y.engineState()
+	y.mutex.Unlock()
+	return samples * bytesPerSample, err
+}
 
-	copy(p, buf)
-	n = len(buf)
-	if n > len(p) {
-		n = len(p)
+// apply hands the settings changed since the last read to the engine and
+// filters. The caller holds both locks.
+func (y *YMPlayer) apply() {
+	if !y.changed {
+		return
+	}
+	y.changed = false
+	if e, ok := y.player.(*ayEngine); ok {
+		e.voices = y.voices
+		e.SetPanning(y.panning)
+	}
+	if y.player != nil {
+		y.player.SetLoopMode(y.loop)
 	}
+	if y.filter != y.dsp.filter {
+		y.dsp.set(y.filter, y.sampleRate)
+	}
+}
 
-	return n, err
+// engineState reads the chip registers from the engine, for State. The
+// caller holds render, or is the only one using the player.
+func (y *YMPlayer) engineState() AYState {
+	var s AYState
+	if y.player == nil {
+		return s
+	}
+	if y.file != nil {
+		s.Frame = max(int(y.player.GetPos())*y.file.FrameRate/1000-1, 0)
+	}
+	for reg := range s.Registers {
+		s.Registers[reg] = byte(y.player.GetRegister(reg))
+	}
+	return s
 }
 
+// Seek moves playback to a byte offset in the 16-bit stereo stream, the
+// unit audio.Player uses. Offsets are rounded down to a whole sample. Like
+// Position, offsets are within one pass of the tune: looping tunes wrap
+// past the end back to the loop frame, others stop at the end.
+//
+// Seeking renders up to the target, so unlike Read it keeps both locks for
+// the whole time; it only happens when a tune starts or restarts.
 func (y *YMPlayer) Seek(offset int64, whence int) (int64, error) {
+	y.render.Lock()
+	defer y.render.Unlock()
 	y.mutex.Lock()
 	defer y.mutex.Unlock()
+	y.apply()
 
 	var newPos int64
 	switch whence {
 	case io.SeekStart:
-		newPos = offset
+		newPos = offset / bytesPerSample
 	case io.SeekCurrent:
-		newPos = y.position + offset
+		newPos = y.tuneSample(y.position) + offset/bytesPerSample
 	case io.SeekEnd:
-		newPos = y.totalSamples + offset
+		newPos = y.totalSamples + offset/bytesPerSample
 	default:
 		return 0, fmt.Errorf("invalid whence: %d", whence)
 	}
 
-	if newPos < 0 {
-		newPos = 0
+	newPos = max(newPos, 0)
+	if y.loop {
+		newPos = y.tuneSample(newPos)
+	} else {
+		newPos = min(newPos, y.totalSamples)
 	}
-	if newPos > y.totalSamples {
-		newPos = y.totalSamples
+
+	if newPos != y.position {
+		if err := y.seekSamples(newPos); err != nil {
+			return y.position * bytesPerSample, err
+		}
 	}
+	return newPos * bytesPerSample, nil
+}
 
-	y.position = newPos
-	return newPos, nil
+// seekSamples restarts the engine at the given sample. The engine can only
+// jump to frame boundaries, and switches to a frame's registers part way
+// through rendering it depending on how reads are chunked, so it is
+// positioned one frame early and the remainder is rendered and thrown away.
+// The envelope shape is only stored when it changes, so the last frame that
+// set it is played first.
+// Engines that can't seek render everything from the start.
+func (y *YMPlayer) seekSamples(target int64) error {
+	player, err := y.newEngine()
+	if err != nil {
+		return err
+	}
+
+	skip := target
+	if player.IsSeekable() && y.frameSamples > 0 {
+		if frame := target / y.frameSamples; frame >= 2 {
+			start := int(frame - 1)
+			if w := y.file.lastEnvelopeWrite(start); w >= 0 && w < start {
+				player.Seek(y.frameTime(w))
+				y.discard(player, y.frameSamples)
+			}
+			player.Seek(y.frameTime(start))
+			skip = target - int64(start)*y.frameSamples
+		}
+	}
+	y.discard(player, skip)
+
+	if y.player != nil {
+		y.player.Destroy()
+	}
+	y.player = player
+	y.position = target
+	y.state = y.engineState()
+	return nil
+}
+
+// frameTime returns the earliest time in milliseconds that the engine maps
+// to the given frame.
+func (y *YMPlayer) frameTime(frame int) uint32 {
+	rate := y.file.FrameRate
+	return uint32((frame*1000 + rate - 1) / rate)
+}
+
+// discard renders samples from player without keeping them.
+func (y *YMPlayer) discard(player ymSynth, samples int64) {
+	for samples > 0 {
+		chunk := int64(len(y.buffer))
+		if chunk > samples {
+			chunk = samples
+		}
+		// Stereo is rendered as played so both sides' filters are primed.
+		if e, ok := player.(*ayEngine); ok && e.Stereo() {
+			e.ComputeStereo(y.stereo[:chunk*2], int(chunk))
+		} else {
+			player.Compute(y.buffer[:chunk], int(chunk))
+		}
+		samples -= chunk
+	}
+}
+
+// Info returns the tune's metadata. It never changes after loading.
+func (y *YMPlayer) Info() SourceInfo {
+	return y.info
+}
+
+// Position returns how far into the tune playback has been rendered.
+// Looping tunes go back to the loop frame at the end, so it stays within
+// Duration.
+func (y *YMPlayer) Position() time.Duration {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	return samplesToDuration(y.tuneSample(y.position), y.sampleRate)
+}
+
+// loopBounds returns the sample a looping tune goes back to and the sample
+// it does so at.
+func (y *YMPlayer) loopBounds() (start, end int64) {
+	if y.file == nil || y.endSample == 0 {
+		return 0, y.totalSamples
+	}
+	return int64(y.file.LoopFrame) * y.frameSamples, y.endSample
+}
+
+// tuneSample maps a sample of the stream to the sample of the tune played
+// then, following the loop like TuneFrame.
+func (y *YMPlayer) tuneSample(pos int64) int64 {
+	start, end := y.loopBounds()
+	if !y.loop || pos < end || end <= start {
+		return pos
+	}
+	return start + (pos-end)%(end-start)
+}
+
+// Duration returns the length of one pass through the tune.
+func (y *YMPlayer) Duration() time.Duration {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	return samplesToDuration(y.totalSamples, y.sampleRate)
+}
+
+func samplesToDuration(samples int64, sampleRate int) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
 }
 
 func (y *YMPlayer) Close() error {
+	y.render.Lock()
+	defer y.render.Unlock()
 	y.mutex.Lock()
 	defer y.mutex.Unlock()
 
@@ -126,8 +395,109 @@ func (y *YMPlayer) Close() error {
 	return nil
 }
 
+// StreamFrame returns the number of whole YM frames in the first pos of the
+// stream, or -1 for tunes without a register stream.
+func (y *YMPlayer) StreamFrame(pos time.Duration) int {
+	if y.file == nil {
+		return -1
+	}
+	return int(int64(durationToSamples(pos, y.sampleRate)) / y.frameSamples)
+}
+
+// TuneFrame maps a stream frame to the frame of the file played then,
+// following the loop.
+func (y *YMPlayer) TuneFrame(frame int) int {
+	if y.file == nil || frame < y.file.Frames {
+		return frame
+	}
+	loop := y.file.Frames - y.file.LoopFrame
+	if loop <= 0 {
+		return y.file.Frames - 1
+	}
+	return y.file.LoopFrame + (frame-y.file.Frames)%loop
+}
+
+// NoteOns returns the voices that start a note in a frame of the file.
+func (y *YMPlayer) NoteOns(frame int) Voices {
+	if y.file == nil {
+		return 0
+	}
+	return y.file.NoteOns(frame)
+}
+
+// SetVoices chooses which AY voices are heard. Tunes played by stsound
+// rather than the AY emulation always play every voice.
+func (y *YMPlayer) SetVoices(voices Voices) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.voices = voices
+	y.changed = true
+}
+
+// SetPanning spreads the AY voices over the stereo field. Tunes played by
+// stsound rather than the AY emulation stay mono.
+func (y *YMPlayer) SetPanning(p Panning) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.panning = p
+	y.changed = true
+}
+
+// SetFilter switches the output filter. It applies to every engine, after
+// the chip output and before the volume.
+func (y *YMPlayer) SetFilter(f OutputFilter) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.filter = f
+	y.changed = true
+}
+
+// SetScope makes Read copy what it renders into ring, or stops it when
+// ring is nil. A ring should only be fed by one player at a time.
+func (y *YMPlayer) SetScope(ring *ScopeRing) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.scope = ring
+}
+
+// Clock returns the chip clock the tune was written for.
+func (y *YMPlayer) Clock() int {
+	if y.file != nil && y.file.Clock > 0 {
+		return y.file.Clock
+	}
+	return ayClock
+}
+
+// State returns the chip registers as last written by the tune, as of the
+// end of the last read.
+func (y *YMPlayer) State() AYState {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	return y.state
+}
+
+// SetLoop changes whether the tune restarts at its loop frame when it ends.
+// The position is brought back into the current pass first, so a tune that
+// has already looped plays the rest of that pass before it ends.
+func (y *YMPlayer) SetLoop(loop bool) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.position = y.tuneSample(y.position)
+	y.loop = loop
+	y.changed = true
+}
+
 func (y *YMPlayer) SetVolume(vol float64) {
 	y.mutex.Lock()
 	defer y.mutex.Unlock()
-	y.volume = vol
+	y.volume.set(vol)
+	y.fades++
+}
+
+// FadeTo ramps the volume to vol over d of rendered audio.
+func (y *YMPlayer) FadeTo(vol float64, d time.Duration) {
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	y.volume.fadeTo(vol, durationToSamples(d, y.sampleRate))
+	y.fades++
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// menuTune is the tune the audio tests play, relative to the package.
+var menuTune = filepath.Join("..", "assets", "menu", "menu.ym")
+
+func loadMenuTune(tb testing.TB) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(menuTune)
+	if err != nil {
+		tb.Skipf("no test tune: %v", err)
+	}
+	return data
+}
+
+// TestPlaybackSpeed plays one pass of the tune at the usual output rates
+// and checks that it lasts as long as the tune says it does, so a wrong
+// replay rate shows up as a pass that is too long or too short.
+func TestPlaybackSpeed(t *testing.T) {
+	data := loadMenuTune(t)
+	for _, rate := range []int{44100, 48000} {
+		player, err := NewYMPlayer(data, rate, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		played := samplesToDuration(int64(readAll(t, player, math.MaxInt)/bytesPerSample), rate)
+		want := player.Info().Duration
+		frame := time.Second / ymFrameRate
+		if diff := played - want; diff < -frame || diff > frame {
+			t.Errorf("%d Hz: one pass played for %s, the tune lasts %s", rate, played, want)
+		}
+		player.Close()
+	}
+}
+
+// benchmarkRead reads a looping tune the way the audio thread does, one
+// 2048-sample chunk per op, and reports the cost of each sample.
+func benchmarkRead(b *testing.B, source func(data []byte) io.Reader) {
+	const samples = 2048
+	r := source(loadMenuTune(b))
+	buf := make([]byte, samples*bytesPerSample)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Read(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*samples), "ns/sample")
+}
+
+func openPlayer(b *testing.B, data []byte, panning Panning) *YMPlayer {
+	y, err := NewYMPlayer(data, defaultSampleRate, true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	y.SetPanning(panning)
+	return y
+}
+
+func BenchmarkYMPlayerRead(b *testing.B) {
+	benchmarkRead(b, func(data []byte) io.Reader {
+		return openPlayer(b, data, MonoPanning)
+	})
+}
+
+func BenchmarkYMPlayerReadStereo(b *testing.B) {
+	benchmarkRead(b, func(data []byte) io.Reader {
+		return openPlayer(b, data, ABCPanning)
+	})
+}
+
+// readAll reads r until it ends or limit bytes have been read, and returns
+// how many bytes it got.
+func readAll(t *testing.T, r io.Reader, limit int) int {
+	t.Helper()
+	buf := make([]byte, 1000*bytesPerSample)
+	total := 0
+	for total < limit {
+		n, err := r.Read(buf)
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return total
+}
+
+func TestLoopingPosition(t *testing.T) {
+	player, err := NewYMPlayer(loadMenuTune(t), defaultSampleRate, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer player.Close()
+	past := readAll(t, player, durationToSamples(player.Duration()+3*time.Second, defaultSampleRate)*bytesPerSample)
+
+	pos := player.Position()
+	if pos >= player.Duration() {
+		t.Errorf("position %s is past the tune's %s", pos, player.Duration())
+	}
+	current, err := player.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := samplesToDuration(current/bytesPerSample, defaultSampleRate); got != pos {
+		t.Errorf("Seek(0, SeekCurrent) is at %s, Position at %s", got, pos)
+	}
+
+	// Seeking past the end lands the same way playing there does.
+	if _, err := player.Seek(int64(past), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if got := player.Position(); got != pos {
+		t.Errorf("seeking to %s of the stream gave %s, playing gave %s",
+			samplesToDuration(int64(past/bytesPerSample), defaultSampleRate), got, pos)
+	}
+}
+
+func TestReadEndsAtLastFrame(t *testing.T) {
+	player, err := NewYMPlayer(loadMenuTune(t), defaultSampleRate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer player.Close()
+	got := readAll(t, player, math.MaxInt)
+	if want := int(player.endSample) * bytesPerSample; got != want {
+		t.Errorf("read %d bytes, want %d", got, want)
+	}
+}
+
+// TestStopLoopingAfterLoop turns looping off part way through the second
+// pass, the way the jukebox does, and expects the rest of that pass to play.
+func TestStopLoopingAfterLoop(t *testing.T) {
+	player, err := NewYMPlayer(loadMenuTune(t), defaultSampleRate, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer player.Close()
+	played := readAll(t, player, (int(player.endSample)+durationToSamples(3*time.Second, defaultSampleRate))*bytesPerSample)
+
+	player.SetLoop(false)
+	pos := player.Position()
+	if pos >= player.Duration() {
+		t.Fatalf("position %s is past the tune's %s", pos, player.Duration())
+	}
+	start, end := player.loopBounds()
+	want := int(end-start)*bytesPerSample - (played - int(end)*bytesPerSample)
+	if got := readAll(t, player, math.MaxInt); got != want {
+		t.Errorf("played %d more bytes after looping was turned off, want %d", got, want)
+	}
+}
+
+// TestControlWhileReading drives the player from another goroutine the way
+// the game does while the audio thread reads, for the race detector.
+func TestControlWhileReading(t *testing.T) {
+	player, err := NewYMPlayer(loadMenuTune(t), defaultSampleRate, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer player.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			player.FadeTo(float64(i%2), 10*time.Millisecond)
+			player.SetVoices(Voices(i % 8))
+			player.SetPanning(ABCPanning)
+			player.Position()
+			player.State()
+		}
+	}()
+	buf := make([]byte, 512*bytesPerSample)
+	for i := 0; i < 200; i++ {
+		if _, err := player.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
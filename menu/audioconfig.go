@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// AudioConfig chooses the output format of the menu. A zero Buffer keeps
+// ebiten's default device buffer.
+type AudioConfig struct {
+	SampleRate int
+	Buffer     time.Duration
+}
+
+var defaultAudioConfig = AudioConfig{SampleRate: defaultSampleRate}
+
+// parseAudioFlags reads the menu's own flags:
+//
+//	menu [-rate N] [-buffer D]
+func parseAudioFlags(args []string) (AudioConfig, error) {
+	config := defaultAudioConfig
+	flags := flag.NewFlagSet("menu", flag.ContinueOnError)
+	flags.IntVar(&config.SampleRate, "rate", config.SampleRate, "audio sample rate in Hz, such as 44100 or 48000")
+	flags.DurationVar(&config.Buffer, "buffer", config.Buffer, "audio device buffer, such as 50ms; 0 keeps the default")
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+	if config.SampleRate < 8000 || config.SampleRate > 192000 {
+		return config, errors.New("rate must be between 8000 and 192000")
+	}
+	// The engines play whole samples per frame, so other rates drift.
+	if config.SampleRate%ymFrameRate != 0 {
+		return config, fmt.Errorf("rate must be a multiple of %d Hz, the YM frame rate", ymFrameRate)
+	}
+	if config.Buffer < 0 {
+		return config, errors.New("buffer can't be negative")
+	}
+	return config, nil
+}
+
+// underrunWatch notices when the device has played everything the mixer
+// rendered, which means the audio thread fell behind and the listener heard
+// a gap.
+type underrunWatch struct {
+	Count   int
+	starved bool
+}
+
+// check compares what the mixer has rendered with what has been played.
+// Only the start of each underrun is counted.
+func (u *underrunWatch) check(rendered, played time.Duration) {
+	starved := played > 0 && rendered <= played
+	if starved && !u.starved {
+		u.Count++
+		log.Printf("audio underrun (%d so far): try a larger -buffer", u.Count)
+	}
+	u.starved = starved
+}
+
+func (g *Game) checkUnderruns() {
+	if g.mixer == nil || g.audioPlayer == nil || !g.audioPlayer.IsPlaying() {
+		return
+	}
+	g.underruns.check(g.mixer.Position(), g.audioPlayer.Position())
+}
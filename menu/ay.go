@@ -0,0 +1,381 @@
+package main
+
+// The YM2149 emulation below follows ST-Sound's CYm2149Ex, the chip inside
+// stsound, but keeps the three voices apart so they can be muted, panned
+// and metered. It is driven by ayEngine from the register stream in YMFile.
+
+const (
+	ayVoices   = 3
+	ayClock    = 2000000
+	mfpClock   = 2457600
+	drumPrec   = 15
+	dcAdjustSz = 512
+)
+
+// Voices selects AY voices as a bit mask, voice A in bit 0.
+type Voices uint8
+
+const allVoices Voices = 1<<ayVoices - 1
+
+func (v Voices) Has(voice int) bool {
+	return v&(1<<voice) != 0
+}
+
+// ayVolume is the YM2149 DAC curve, scaled so that three voices at full
+// volume fit in a 16-bit sample.
+var ayVolume = [16]int32{20, 53, 88, 125, 193, 258, 385, 525, 753, 1029, 1523, 2077, 3110, 4395, 7073, 10922}
+
+// ayEnvelopeWaves gives, for each envelope shape, the start and end level of
+// its four 16-step phases. The first two phases play once and the last two
+// repeat.
+var ayEnvelopeWaves = [16][8]int{
+	{1, 0, 0, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0, 0, 0},
+	{0, 1, 0, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0, 0, 0},
+	{1, 0, 1, 0, 1, 0, 1, 0}, {1, 0, 0, 0, 0, 0, 0, 0}, {1, 0, 0, 1, 1, 0, 0, 1}, {1, 0, 1, 1, 1, 1, 1, 1},
+	{0, 1, 0, 1, 0, 1, 0, 1}, {0, 1, 1, 1, 1, 1, 1, 1}, {0, 1, 1, 0, 0, 1, 1, 0}, {0, 1, 0, 0, 0, 0, 0, 0},
+}
+
+// ayEnvelope holds the level for every shape, phase and step.
+var ayEnvelope = func() (env [16][2][32]int) {
+	for shape, wave := range ayEnvelopeWaves {
+		for phase := 0; phase < 4; phase++ {
+			a, b := wave[phase*2], wave[phase*2+1]
+			for i := 0; i < 16; i++ {
+				env[shape][phase/2][(phase%2)*16+i] = min(max(a*15+(b-a)*i, 0), 15)
+			}
+		}
+	}
+	return env
+}()
+
+type ayVoice struct {
+	step     uint32
+	pos      uint32
+	volume   int32
+	envelope bool
+	toneOff  bool
+	noiseOff bool
+
+	sid       bool
+	sidPos    uint32
+	sidStep   uint32
+	sidVolume byte
+
+	drum     []byte
+	drumPos  uint32
+	drumStep uint32
+}
+
+// AYChip emulates one YM2149 at a given output sample rate.
+type AYChip struct {
+	clock      uint32
+	sampleRate uint32
+	regs       [14]byte
+	voices     [ayVoices]ayVoice
+
+	noiseStep uint32
+	noisePos  uint32
+	rng       uint32
+	noise     bool
+
+	envStep  uint32
+	envPos   uint32
+	envPhase int
+	envShape int
+
+	buzzerStep  uint32
+	buzzerPhase uint32
+
+	// Levels holds each voice's output for the last sample, before muting.
+	Levels [ayVoices]int32
+	Filter bool
+
+	lowpass      [2]int
+	dc           dcAdjuster
+	lowpassRight [2]int
+	dcRight      dcAdjuster
+}
+
+type dcAdjuster struct {
+	buffer [dcAdjustSz]int32
+	pos    int
+	sum    int32
+}
+
+func (d *dcAdjuster) add(sample int32) int32 {
+	d.sum += sample - d.buffer[d.pos]
+	d.buffer[d.pos] = sample
+	d.pos = (d.pos + 1) % dcAdjustSz
+	return d.sum / dcAdjustSz
+}
+
+func NewAYChip(clock, sampleRate int) *AYChip {
+	c := &AYChip{clock: uint32(clock), sampleRate: uint32(sampleRate), Filter: true}
+	c.Reset()
+	return c
+}
+
+func (c *AYChip) Reset() {
+	c.regs = [14]byte{}
+	for reg := 0; reg < len(c.regs); reg++ {
+		c.WriteRegister(reg, 0)
+	}
+	c.WriteRegister(7, 0xff)
+	c.noise = true
+	c.rng = 1
+	for i := range c.voices {
+		c.voices[i].sid = false
+		c.voices[i].sidPos, c.voices[i].sidStep, c.voices[i].sidVolume = 0, 0, 0
+		c.voices[i].drum = nil
+		c.voices[i].drumPos, c.voices[i].drumStep = 0, 0
+	}
+	c.envShape, c.envPhase, c.envPos = 0, 0, 0
+	c.dc, c.dcRight = dcAdjuster{}, dcAdjuster{}
+	c.StopBuzzer()
+	c.lowpass, c.lowpassRight = [2]int{}, [2]int{}
+}
+
+func (c *AYChip) Register(reg int) byte {
+	if reg < 0 || reg >= len(c.regs) {
+		return 0
+	}
+	return c.regs[reg]
+}
+
+func (c *AYChip) toneStep(hi, lo byte) uint32 {
+	period := int64(hi&15)<<8 | int64(lo)
+	if period <= 5 {
+		return 0
+	}
+	return uint32(int64(c.clock) << 28 / (period * int64(c.sampleRate)))
+}
+
+func (c *AYChip) WriteRegister(reg int, value byte) {
+	switch reg {
+	case 0, 1, 2, 3, 4, 5:
+		if reg&1 == 1 {
+			value &= 15
+		}
+		c.regs[reg] = value
+		v := &c.voices[reg/2]
+		v.step = c.toneStep(c.regs[reg|1], c.regs[reg&^1])
+		if v.step == 0 {
+			v.pos = 1 << 31
+		}
+	case 6:
+		c.regs[6] = value & 0x1f
+		c.noiseStep = 0
+		if period := int64(c.regs[6]); period >= 3 {
+			c.noiseStep = uint32(int64(c.clock) << 12 / (period * int64(c.sampleRate)))
+		}
+		if c.noiseStep == 0 {
+			c.noisePos = 0
+			c.noise = true
+		}
+	case 7:
+		c.regs[7] = value
+		for i := range c.voices {
+			c.voices[i].toneOff = value&(1<<i) != 0
+			c.voices[i].noiseOff = value&(8<<i) != 0
+		}
+	case 8, 9, 10:
+		c.regs[reg] = value & 31
+		v := &c.voices[reg-8]
+		v.volume = ayVolume[value&15]
+		v.envelope = value&0x10 != 0
+	case 11, 12:
+		c.regs[reg] = value
+		c.envStep = 0
+		if period := int64(c.regs[12])<<8 | int64(c.regs[11]); period >= 3 {
+			c.envStep = uint32(int64(c.clock) << 23 / (period * int64(c.sampleRate)))
+		}
+	case 13:
+		c.regs[13] = value & 15
+		c.envPos = 0
+		c.envPhase = 0
+		c.envShape = int(value & 15)
+	}
+}
+
+// StartSID gates a voice between its volume and silence at freq Hz.
+func (c *AYChip) StartSID(voice, freq int, volume byte) {
+	v := &c.voices[voice]
+	v.sidStep = uint32(freq) * ((1 << 31) / c.sampleRate)
+	v.sidVolume = volume & 15
+	v.sid = true
+}
+
+func (c *AYChip) StopSID(voice int) {
+	c.voices[voice].sid = false
+}
+
+// StartDrum plays an 8-bit sample on a voice at freq Hz.
+func (c *AYChip) StartDrum(voice int, sample []byte, freq int) {
+	if len(sample) == 0 {
+		return
+	}
+	v := &c.voices[voice]
+	v.drum = sample
+	v.drumPos = 0
+	v.drumStep = uint32(freq<<drumPrec) / c.sampleRate
+}
+
+// StartBuzzer restarts the envelope at freq Hz with the given shape.
+func (c *AYChip) StartBuzzer(freq int, shape byte) {
+	c.envShape = int(shape & 15)
+	c.buzzerStep = uint32(freq) * ((1 << 31) / c.sampleRate)
+	c.buzzerPhase = 0
+}
+
+func (c *AYChip) StopBuzzer() {
+	c.buzzerStep = 0
+	c.buzzerPhase = 0
+}
+
+// next renders one sample of the voices in audible, after DC removal and
+// the optional low-pass filter. The level of every voice, muted or not, is
+// left in Levels.
+func (c *AYChip) next(audible Voices) int16 {
+	c.step()
+	var mix int32
+	for i, level := range c.Levels {
+		if audible.Has(i) {
+			mix += level
+		}
+	}
+	return c.output(mix, &c.dc, &c.lowpass)
+}
+
+// nextStereo is next with each voice spread over the two sides by gains,
+// as returned by Panning.gains. Each side has its own DC removal and filter.
+func (c *AYChip) nextStereo(audible Voices, gains *[ayVoices][2]float64) (int16, int16) {
+	c.step()
+	var left, right float64
+	for i, level := range c.Levels {
+		if audible.Has(i) {
+			left += float64(level) * gains[i][0]
+			right += float64(level) * gains[i][1]
+		}
+	}
+	return c.output(int32(left), &c.dc, &c.lowpass), c.output(int32(right), &c.dcRight, &c.lowpassRight)
+}
+
+// step advances the chip by one sample and leaves each voice's level in
+// Levels.
+func (c *AYChip) step() {
+	if c.noisePos&0xffff0000 != 0 {
+		bit := (c.rng ^ c.rng>>2) & 1
+		c.rng = c.rng>>1 | bit<<16
+		if bit == 0 {
+			c.noise = !c.noise
+		}
+		c.noisePos &= 0xffff
+	}
+	envLevel := ayVolume[ayEnvelope[c.envShape][c.envPhase][c.envPos>>27]]
+
+	for i := range c.voices {
+		v := &c.voices[i]
+		volume := v.volume
+		toneOff, noiseOff, envelope := v.toneOff, v.noiseOff, v.envelope
+		if v.sid {
+			level := byte(0)
+			if v.sidPos&(1<<31) != 0 {
+				level = v.sidVolume
+			}
+			c.WriteRegister(8+i, level)
+			volume, envelope = v.volume, false
+		} else if v.drum != nil {
+			// Like the original, the drum takes over the voice until the
+			// next frame rewrites its registers.
+			v.volume = int32(v.drum[v.drumPos>>drumPrec]) * 255 / 6
+			v.toneOff, v.noiseOff, v.envelope = true, true, false
+			volume, toneOff, noiseOff, envelope = v.volume, true, true, false
+			v.drumPos += v.drumStep
+			if int(v.drumPos>>drumPrec) >= len(v.drum) {
+				v.drum = nil
+			}
+		}
+		if envelope {
+			volume = envLevel
+		}
+
+		tone := v.pos&(1<<31) != 0 || toneOff
+		if !tone || !(c.noise || noiseOff) {
+			volume = 0
+		}
+		c.Levels[i] = volume
+		v.pos += v.step
+		v.sidPos += v.sidStep
+	}
+
+	c.noisePos += c.noiseStep
+	c.envPos += c.envStep
+	if c.envPhase == 0 && c.envPos < c.envStep {
+		c.envPhase = 1
+	}
+	c.buzzerPhase += c.buzzerStep
+	if c.buzzerPhase&(1<<31) != 0 {
+		c.envPos = 0
+		c.envPhase = 0
+		c.buzzerPhase &= 0x7fffffff
+	}
+}
+
+// output removes DC from mix and filters it. Panned voices can sum past
+// the 16-bit range, so the result is clamped rather than left to wrap.
+func (c *AYChip) output(mix int32, dc *dcAdjuster, lowpass *[2]int) int16 {
+	in := int(mix - dc.add(mix))
+	out := in
+	if c.Filter {
+		out = lowpass[0]>>2 + lowpass[1]>>1 + in>>2
+		lowpass[0], lowpass[1] = lowpass[1], in
+	}
+	return int16(min(max(out, -32768), 32767))
+}
+
+// AYState is a snapshot of the chip registers with helpers to decode them.
+type AYState struct {
+	Frame     int
+	Registers [14]byte
+}
+
+func (s AYState) TonePeriod(voice int) int {
+	return int(s.Registers[voice*2+1]&15)<<8 | int(s.Registers[voice*2])
+}
+
+// ToneFrequency converts a voice's tone period to Hz for the given clock.
+func (s AYState) ToneFrequency(voice, clock int) float64 {
+	period := s.TonePeriod(voice)
+	if period == 0 {
+		return 0
+	}
+	return float64(clock) / float64(16*period)
+}
+
+func (s AYState) Volume(voice int) int {
+	return int(s.Registers[8+voice] & 15)
+}
+
+func (s AYState) UsesEnvelope(voice int) bool {
+	return s.Registers[8+voice]&0x10 != 0
+}
+
+func (s AYState) ToneEnabled(voice int) bool {
+	return s.Registers[7]&(1<<voice) == 0
+}
+
+func (s AYState) NoiseEnabled(voice int) bool {
+	return s.Registers[7]&(8<<voice) == 0
+}
+
+func (s AYState) NoisePeriod() int {
+	return int(s.Registers[6] & 0x1f)
+}
+
+func (s AYState) EnvelopePeriod() int {
+	return int(s.Registers[12])<<8 | int(s.Registers[11])
+}
+
+func (s AYState) EnvelopeShape() int {
+	return int(s.Registers[13] & 15)
+}
@@ -0,0 +1,226 @@
+package main
+
+// mfpPredivisors are the MFP timer prescalers used by YM5 and YM6 effects.
+var mfpPredivisors = [8]int{0, 4, 10, 16, 50, 64, 100, 200}
+
+// ymSynth renders a tune to mono samples. stsound implements it for every
+// format; ayEngine replaces it for register-stream tunes so that voices can
+// be treated separately.
+type ymSynth interface {
+	Compute(buf []int16, n int) bool
+	SetLoopMode(loop bool)
+	Seek(ms uint32)
+	IsSeekable() bool
+	GetPos() uint32
+	GetRegister(reg int) int
+	Destroy()
+}
+
+// ayEngine plays a YMFile on AYChip, one frame of registers at a time,
+// with the same frame timing as stsound.
+type ayEngine struct {
+	file       *YMFile
+	chip       *AYChip
+	drums      [][]byte
+	sampleRate int
+	frame      int
+	inner      int
+	loop       bool
+	over       bool
+	voices     Voices
+	// pan holds the stereo gains per voice, or nil to render in mono.
+	pan *[ayVoices][2]float64
+	// levels, when set, receives each voice's level for every sample
+	// rendered by the last call.
+	levels [][ayVoices]int32
+}
+
+// ayEngineSupports reports whether ayEngine can play the file. YM2 relies
+// on drum samples built into the Mad Max replay, which only stsound has.
+func ayEngineSupports(f *YMFile) bool {
+	switch f.Format {
+	case "YM3!", "YM3b", "YM5!", "YM6!":
+		return f.Frames > 0 && f.FrameRate > 0
+	}
+	return false
+}
+
+func newAYEngine(file *YMFile, sampleRate int, loop bool, voices Voices) *ayEngine {
+	e := &ayEngine{
+		file:       file,
+		chip:       NewAYChip(file.Clock, sampleRate),
+		sampleRate: sampleRate,
+		loop:       loop,
+		voices:     voices,
+	}
+	for _, drum := range file.DigiDrums {
+		if file.Attributes&ymAttrDrum4Bits != 0 {
+			// Four-bit drums hold chip volumes rather than samples.
+			converted := make([]byte, len(drum))
+			for i, v := range drum {
+				converted[i] = byte(ayVolume[v&15] >> 7)
+			}
+			drum = converted
+		}
+		e.drums = append(e.drums, drum)
+	}
+	return e
+}
+
+func (e *ayEngine) Compute(buf []int16, n int) bool {
+	return e.render(buf[:n], nil)
+}
+
+// ComputeStereo renders n interleaved left and right samples into buf.
+func (e *ayEngine) ComputeStereo(buf []int16, n int) bool {
+	return e.render(nil, buf[:n*2])
+}
+
+func (e *ayEngine) SetPanning(p Panning) {
+	if p.Mono() {
+		e.pan = nil
+		return
+	}
+	gains := p.gains()
+	e.pan = &gains
+}
+
+// Stereo reports whether the engine renders with panning.
+func (e *ayEngine) Stereo() bool {
+	return e.pan != nil
+}
+
+// render fills either mono or interleaved stereo samples.
+func (e *ayEngine) render(mono, stereo []int16) bool {
+	if e.over {
+		clear(mono)
+		clear(stereo)
+		return false
+	}
+	frameSamples := e.sampleRate / e.file.FrameRate
+	n := len(mono) + len(stereo)/2
+	for i := 0; i < n; {
+		count := min(frameSamples-e.inner, n-i)
+		e.inner += count
+		if e.inner >= frameSamples {
+			e.playFrame()
+			e.inner -= frameSamples
+		}
+		for end := i + count; i < end; i++ {
+			if stereo != nil {
+				stereo[i*2], stereo[i*2+1] = e.chip.nextStereo(e.voices, e.pan)
+			} else {
+				mono[i] = e.chip.next(e.voices)
+			}
+			if i < len(e.levels) {
+				e.levels[i] = e.chip.Levels
+			}
+		}
+	}
+	return true
+}
+
+func (e *ayEngine) playFrame() {
+	if e.frame >= e.file.Frames {
+		if !e.loop {
+			e.over = true
+			e.chip.Reset()
+			return
+		}
+		e.frame = e.file.LoopFrame
+	}
+
+	var regs [16]byte
+	for reg := range regs {
+		regs[reg] = e.file.Register(e.frame, reg)
+	}
+	for reg := 0; reg <= 10; reg++ {
+		e.chip.WriteRegister(reg, regs[reg])
+	}
+	for voice := 0; voice < ayVoices; voice++ {
+		e.chip.StopSID(voice)
+	}
+	e.chip.StopBuzzer()
+	e.chip.WriteRegister(11, regs[11])
+	e.chip.WriteRegister(12, regs[12])
+	if regs[13] != 0xff {
+		e.chip.WriteRegister(13, regs[13])
+	}
+
+	switch e.file.Format {
+	case "YM5!":
+		e.ym5Effects(regs)
+	case "YM6!":
+		e.ym6Effect(regs, 1, 6, 14)
+		e.ym6Effect(regs, 3, 8, 15)
+	}
+	e.frame++
+}
+
+func (e *ayEngine) ym5Effects(regs [16]byte) {
+	if code := int(regs[1]>>4) & 3; code != 0 {
+		voice := code - 1
+		if div := mfpPredivisors[regs[6]>>5&7] * int(regs[14]); div != 0 {
+			e.chip.StartSID(voice, mfpClock/div, regs[voice+8])
+		}
+	}
+	if code := int(regs[3]>>4) & 3; code != 0 {
+		voice := code - 1
+		drum := int(regs[8+voice] & 31)
+		if div := mfpPredivisors[regs[8]>>5&7] * int(regs[15]); div != 0 && drum < len(e.drums) {
+			e.chip.StartDrum(voice, e.drums[drum], mfpClock/div)
+		}
+	}
+}
+
+// ym6Effect decodes one of the two effect slots of a YM6 frame. The high
+// bits of the code register select the effect and voice, and the timer
+// that drives it is split over the predivisor and count registers.
+func (e *ayEngine) ym6Effect(regs [16]byte, code, prediv, count int) {
+	effect := regs[code] & 0xf0
+	if effect&0x30 == 0 {
+		return
+	}
+	voice := int(effect&0x30>>4) - 1
+	div := mfpPredivisors[regs[prediv]>>5&7] * int(regs[count])
+	if div == 0 {
+		return
+	}
+	freq := mfpClock / div
+	switch effect & 0xc0 {
+	case 0x00:
+		e.chip.StartSID(voice, freq, regs[voice+8])
+	case 0x40:
+		if drum := int(regs[voice+8] & 31); drum < len(e.drums) {
+			e.chip.StartDrum(voice, e.drums[drum], freq)
+		}
+	case 0xc0:
+		e.chip.StartBuzzer(freq, regs[voice+8])
+	}
+}
+
+func (e *ayEngine) SetLoopMode(loop bool) {
+	e.loop = loop
+}
+
+func (e *ayEngine) Seek(ms uint32) {
+	frame := int(ms) * e.file.FrameRate / 1000
+	if frame >= e.file.Frames {
+		frame = 0
+	}
+	e.frame = frame
+}
+
+func (e *ayEngine) IsSeekable() bool {
+	return true
+}
+
+func (e *ayEngine) GetPos() uint32 {
+	return uint32(e.frame * 1000 / e.file.FrameRate)
+}
+
+func (e *ayEngine) GetRegister(reg int) int {
+	return int(e.chip.Register(reg))
+}
+
+func (e *ayEngine) Destroy() {}
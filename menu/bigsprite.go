@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	bigSpriteSize       = 128
+	bigSpriteFrames     = 8
+	bigSpriteCount      = 48
+	bigSpriteMinCount   = 1
+	bigSpriteMaxCount   = 4096
+	bigSpriteCountStep  = 8
+	bigSpriteTrailDelay = 0.045
+	bigSpriteBenchStep  = 32
+	// bigSpriteBenchSlack is how much longer than at the start of the
+	// benchmark a frame may take before the count counts as too high.
+	bigSpriteBenchSlack = 1.1
+)
+
+// lissajous describes the path followed by the head of the sprite trail.
+type lissajous struct {
+	FreqX  float64 `json:"freqX"`
+	FreqY  float64 `json:"freqY"`
+	Phase  float64 `json:"phase"`
+	Spin   float64 `json:"spin"`
+	Radius float64 `json:"radius"`
+}
+
+var bigSpritePaths = []lissajous{
+	{FreqX: 1.0, FreqY: 2.0, Phase: 0, Spin: 0, Radius: 1},
+	{FreqX: 3.0, FreqY: 2.0, Phase: math.Pi / 2, Spin: 0, Radius: 1},
+	{FreqX: 1.3, FreqY: 1.7, Phase: math.Pi / 4, Spin: 0.4, Radius: 0.7},
+	{FreqX: 2.0, FreqY: 3.0, Phase: 0, Spin: 0.25, Radius: 0.7},
+	{FreqX: 5.0, FreqY: 4.0, Phase: math.Pi / 3, Spin: 0, Radius: 0.9},
+}
+
+func (l lissajous) Point(t, width, height float64) (float64, float64) {
+	x := math.Sin(t*l.FreqX + l.Phase)
+	y := math.Sin(t * l.FreqY)
+	if l.Spin != 0 {
+		s, c := math.Sincos(t * l.Spin)
+		x, y = x*c-y*s, x*s+y*c
+	}
+	return x * width * l.Radius, y * height * l.Radius
+}
+
+// spriteBenchmark ramps up the sprite count until the frame rate drops,
+// remembering the highest count that still ran at full speed. Full speed is
+// the frame time measured with the first few sprites, so the benchmark
+// works whatever the display's refresh rate.
+type spriteBenchmark struct {
+	Running  bool
+	Best     int
+	Ticks    int
+	Baseline time.Duration
+
+	frames   int
+	elapsed  time.Duration
+	lastDraw time.Time
+}
+
+// drawn records the time between frames.
+func (b *spriteBenchmark) drawn(now time.Time) {
+	if !b.lastDraw.IsZero() {
+		b.elapsed += now.Sub(b.lastDraw)
+		b.frames++
+	}
+	b.lastDraw = now
+}
+
+// frameTime returns the average time between the frames drawn since it was
+// last called.
+func (b *spriteBenchmark) frameTime() time.Duration {
+	if b.frames == 0 {
+		return 0
+	}
+	t := b.elapsed / time.Duration(b.frames)
+	b.elapsed, b.frames = 0, 0
+	return t
+}
+
+type BigSpriteScreen struct {
+	tiles *TileSet
+	anim  Animation
+	count int
+	path  int
+	time  float64
+	bench spriteBenchmark
+}
+
+func newBigSpriteScreen(_ *Game) Screen {
+	sheet := loadImage(screenAssetPath("BIG_SPRITE", "sprite.png"), makePlaceholderBigSprite)
+	indices := make([]int, bigSpriteFrames)
+	for i := range indices {
+		indices[i] = i
+	}
+	return &BigSpriteScreen{
+		tiles: NewTileSet(sheet, bigSpriteSize, bigSpriteSize),
+		anim:  Animation{Duration: 0.5, Indices: indices, Loop: true},
+		count: bigSpriteCount,
+	}
+}
+
+func (s *BigSpriteScreen) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) || repeatingKey(ebiten.KeyUp) {
+		s.SetCount(s.count + bigSpriteCountStep)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) || repeatingKey(ebiten.KeyDown) {
+		s.SetCount(s.count - bigSpriteCountStep)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		s.path = (s.path + 1) % len(bigSpritePaths)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+		s.path = (s.path + len(bigSpritePaths) - 1) % len(bigSpritePaths)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		s.toggleBenchmark()
+	}
+	if s.bench.Running {
+		s.updateBenchmark()
+	}
+	s.time += 1.0 / 60.0
+	return nil
+}
+
+func (s *BigSpriteScreen) SetCount(count int) {
+	if count < bigSpriteMinCount {
+		count = bigSpriteMinCount
+	}
+	if count > bigSpriteMaxCount {
+		count = bigSpriteMaxCount
+	}
+	s.count = count
+}
+
+func (s *BigSpriteScreen) toggleBenchmark() {
+	if s.bench.Running {
+		s.bench.Running = false
+		return
+	}
+	s.bench = spriteBenchmark{Running: true}
+	s.SetCount(bigSpriteBenchStep)
+}
+
+func (s *BigSpriteScreen) updateBenchmark() {
+	// Give the frame rate a second to settle after every step.
+	s.bench.Ticks++
+	if s.bench.Ticks < 60 {
+		return
+	}
+	s.bench.Ticks = 0
+	frameTime := s.bench.frameTime()
+	if s.bench.Baseline == 0 {
+		s.bench.Baseline = frameTime
+	}
+	slow := float64(frameTime) > float64(s.bench.Baseline)*bigSpriteBenchSlack
+	if slow || s.count >= bigSpriteMaxCount {
+		s.bench.Running = false
+		log.Printf("BIG_SPRITE benchmark: %d sprites per frame at full speed", s.bench.Best)
+		return
+	}
+	s.bench.Best = s.count
+	s.SetCount(s.count + bigSpriteBenchStep)
+}
+
+func (s *BigSpriteScreen) Draw(dst *ebiten.Image) {
+	if s.bench.Running {
+		s.bench.drawn(time.Now())
+	}
+	w := float64(dst.Bounds().Dx())
+	h := float64(dst.Bounds().Dy())
+	centerX := w*0.5 - bigSpriteSize/2
+	centerY := h*0.5 - bigSpriteSize/2
+	radiusX := (w - bigSpriteSize) * 0.5
+	radiusY := (h - bigSpriteSize) * 0.5
+	path := bigSpritePaths[s.path]
+
+	// Oldest copy first so the head of the trail ends up on top.
+	var op ebiten.DrawImageOptions
+	for i := s.count - 1; i >= 0; i-- {
+		t := s.time - float64(i)*bigSpriteTrailDelay
+		x, y := path.Point(t, radiusX, radiusY)
+		op.GeoM.Reset()
+		op.GeoM.Translate(math.Floor(centerX+x), math.Floor(centerY+y))
+		dst.DrawImage(s.tiles.Tile(s.anim.Current(t)), &op)
+	}
+
+	status := fmt.Sprintf("SPRITES PER FRAME: %d\nFPS: %.1f  TPS: %.1f\nUP/DOWN COUNT  LEFT/RIGHT PATH  B BENCHMARK",
+		s.count, ebiten.ActualFPS(), ebiten.ActualTPS())
+	switch {
+	case s.bench.Running:
+		status += fmt.Sprintf("\nBENCHMARK RUNNING, BEST SO FAR: %d", s.bench.Best)
+	case s.bench.Best > 0:
+		status += fmt.Sprintf("\nBENCHMARK RESULT: %d SPRITES AT FULL SPEED", s.bench.Best)
+	}
+	ebitenutil.DebugPrintAt(dst, status, 8, 8)
+}
+
+// repeatingKey reports a held key at a fixed rate after an initial delay.
+func repeatingKey(key ebiten.Key) bool {
+	const (
+		delay    = 20
+		interval = 3
+	)
+	d := inpututil.KeyPressDuration(key)
+	return d >= delay && (d-delay)%interval == 0
+}
+
+func makePlaceholderBigSprite() *ebiten.Image {
+	img := image.NewRGBA(image.Rect(0, 0, bigSpriteSize*bigSpriteFrames, bigSpriteSize))
+	r := float64(bigSpriteSize)/2 - 1
+	for f := 0; f < bigSpriteFrames; f++ {
+		angle := float64(f) / bigSpriteFrames * 2 * math.Pi
+		lightX := math.Cos(angle) * r * 0.45
+		lightY := -r * 0.4
+		for y := 0; y < bigSpriteSize; y++ {
+			for x := 0; x < bigSpriteSize; x++ {
+				dx := float64(x) - r
+				dy := float64(y) - r
+				if dx*dx+dy*dy > r*r {
+					continue
+				}
+				lx := dx - lightX
+				ly := dy - lightY
+				shade := 1 - math.Sqrt(lx*lx+ly*ly)/(r*1.6)
+				if shade < 0.15 {
+					shade = 0.15
+				}
+				stripe := 0.85
+				if int(math.Floor((dx+dy+float64(f)*4)/12))%2 == 0 {
+					stripe = 1
+				}
+				img.Set(f*bigSpriteSize+x, y, color.RGBA{
+					R: uint8(230 * shade * stripe),
+					G: uint8(90 * shade * stripe),
+					B: uint8(200 * shade),
+					A: 255,
+				})
+			}
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	colorShockMaxColors = 16
+	colorShockMaxBars   = 8
+
+	// safeFlashesPerSecond follows the common three-flashes-per-second
+	// guideline for photosensitive viewers.
+	safeFlashesPerSecond = 3
+	safeFlashStrength    = 0.35
+
+	// barPulse is how much taller the raster bars get on a note.
+	barPulse = 0.5
+)
+
+type ColorSet struct {
+	Name          string   `json:"name"`
+	Colors        []string `json:"colors"`
+	FramesPerStep float64  `json:"framesPerStep"`
+	LineHeight    float64  `json:"lineHeight"`
+	Bars          int      `json:"bars"`
+	BarHeight     float64  `json:"barHeight"`
+	FlashEvery    int      `json:"flashEvery"`
+
+	palette []float32
+	size    int
+}
+
+type ColorShockConfig struct {
+	SafeMode bool       `json:"safeMode"`
+	Sets     []ColorSet `json:"sets"`
+}
+
+// defaultColorSets are cycled unless assets/colorshockii has a colors.json
+// of its own.
+//
+//go:embed colors.json
+var defaultColorSets []byte
+
+func loadColorShockConfig(path string) ColorShockConfig {
+	data := defaultColorSets
+	if custom, err := os.ReadFile(path); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to read colour sets %s (%v), using defaults", path, err)
+		}
+	} else {
+		data = custom
+	}
+
+	config, err := parseColorShockConfig(data)
+	if err != nil {
+		log.Printf("failed to parse %s (%v), using defaults", path, err)
+	}
+	if len(config.Sets) == 0 {
+		defaults, _ := parseColorShockConfig(defaultColorSets)
+		config.Sets = defaults.Sets
+	}
+	return config
+}
+
+// parseColorShockConfig reads a colors.json, dropping any set that cannot
+// be shown. Safe mode is on unless the file turns it off.
+func parseColorShockConfig(data []byte) (ColorShockConfig, error) {
+	config := ColorShockConfig{SafeMode: true}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ColorShockConfig{SafeMode: true}, err
+	}
+	config.Sets = prepareColorSets(config.Sets)
+	return config, nil
+}
+
+func prepareColorSets(sets []ColorSet) []ColorSet {
+	result := make([]ColorSet, 0, len(sets))
+	for _, set := range sets {
+		if err := set.prepare(); err != nil {
+			log.Printf("skipping colour set %q: %v", set.Name, err)
+			continue
+		}
+		result = append(result, set)
+	}
+	return result
+}
+
+func (c *ColorSet) prepare() error {
+	if len(c.Colors) == 0 {
+		return fmt.Errorf("no colours")
+	}
+	if len(c.Colors) > colorShockMaxColors {
+		return fmt.Errorf("%d colours, at most %d allowed", len(c.Colors), colorShockMaxColors)
+	}
+	c.palette = make([]float32, colorShockMaxColors*4)
+	for i, hex := range c.Colors {
+		col, err := parseHexColor(hex)
+		if err != nil {
+			return err
+		}
+		c.palette[i*4] = float32(col.R) / 255
+		c.palette[i*4+1] = float32(col.G) / 255
+		c.palette[i*4+2] = float32(col.B) / 255
+		c.palette[i*4+3] = 1
+	}
+	c.size = len(c.Colors)
+	if c.FramesPerStep <= 0 {
+		c.FramesPerStep = 1
+	}
+	if c.LineHeight <= 0 {
+		c.LineHeight = 1
+	}
+	if c.Bars > colorShockMaxBars {
+		c.Bars = colorShockMaxBars
+	}
+	if c.BarHeight <= 0 {
+		c.BarHeight = 16
+	}
+	return nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// flashLimiter drops flashes that would exceed a maximum rate.
+type flashLimiter struct {
+	recent []float64
+}
+
+func (f *flashLimiter) Allow(now float64, perSecond int) bool {
+	kept := f.recent[:0]
+	for _, t := range f.recent {
+		if now-t < 1 {
+			kept = append(kept, t)
+		}
+	}
+	f.recent = kept
+	if perSecond > 0 && len(f.recent) >= perSecond {
+		return false
+	}
+	f.recent = append(f.recent, now)
+	return true
+}
+
+type ColorShockScreen struct {
+	clock     *MusicClock
+	shader    *ebiten.Shader
+	config    ColorShockConfig
+	set       int
+	time      float64
+	flash     float64
+	lastFlash int
+	limiter   flashLimiter
+	// beat is set by frame events that fall on a flash.
+	beat        bool
+	unsubscribe func()
+}
+
+func newColorShockScreen(g *Game) Screen {
+	shader, err := ebiten.NewShader([]byte(colorShockShaderSrc))
+	if err != nil {
+		log.Printf("failed to compile COLORSHOCK_II shader: %v", err)
+		return nil
+	}
+	s := &ColorShockScreen{
+		clock:     &g.clock,
+		shader:    shader,
+		config:    loadColorShockConfig(screenAssetPath("COLORSHOCK_II", "colors.json")),
+		lastFlash: -1,
+	}
+	s.unsubscribe = g.clock.Subscribe(s.musicEvent)
+	return s
+}
+
+// musicEvent marks a beat on the frames of the tune the flashes fall on,
+// which follow the tune back to its loop frame.
+func (s *ColorShockScreen) musicEvent(e MusicEvent) {
+	every := s.config.Sets[s.set].FlashEvery
+	if e.Kind == MusicFrame && every > 0 && e.Frame%every == 0 {
+		s.beat = true
+	}
+}
+
+func (s *ColorShockScreen) Close() {
+	s.unsubscribe()
+}
+
+// musicFrames returns the position of the music heard in YM frames,
+// falling back to the screen's own clock when no tune is playing.
+func (s *ColorShockScreen) musicFrames() float64 {
+	if s.clock.Playing {
+		return s.clock.Seconds() * ymFrameRate
+	}
+	return s.time * ymFrameRate
+}
+
+func (s *ColorShockScreen) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		s.set = (s.set + 1) % len(s.config.Sets)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+		s.set = (s.set + len(s.config.Sets) - 1) % len(s.config.Sets)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		s.config.SafeMode = !s.config.SafeMode
+	}
+	s.time += 1.0 / 60.0
+
+	set := s.config.Sets[s.set]
+	s.flash *= 0.8
+	switch {
+	case s.clock.Events():
+		if s.beat {
+			s.triggerFlash()
+		}
+	case set.FlashEvery > 0:
+		beat := int(s.musicFrames()) / set.FlashEvery
+		if beat != s.lastFlash {
+			s.lastFlash = beat
+			s.triggerFlash()
+		}
+	}
+	s.beat = false
+	return nil
+}
+
+func (s *ColorShockScreen) triggerFlash() {
+	if !s.config.SafeMode {
+		s.flash = 1
+		return
+	}
+	if s.limiter.Allow(s.time, safeFlashesPerSecond) {
+		s.flash = safeFlashStrength
+	}
+}
+
+// rotation returns the palette offset, capped in safe mode so the whole
+// screen never cycles through colours faster than the flash limit.
+func (s *ColorShockScreen) rotation(set ColorSet) float64 {
+	frames := s.musicFrames()
+	steps := frames / set.FramesPerStep
+	if s.config.SafeMode && ymFrameRate/set.FramesPerStep > safeFlashesPerSecond {
+		steps = frames / ymFrameRate * safeFlashesPerSecond
+	}
+	return math.Mod(steps, float64(set.size))
+}
+
+func (s *ColorShockScreen) Draw(dst *ebiten.Image) {
+	set := s.config.Sets[s.set]
+	w := dst.Bounds().Dx()
+	h := dst.Bounds().Dy()
+
+	op := &ebiten.DrawRectShaderOptions{}
+	op.Uniforms = map[string]any{
+		"Palette":     set.palette,
+		"PaletteSize": float32(set.size),
+		"Rotation":    float32(s.rotation(set)),
+		"LineHeight":  float32(set.LineHeight),
+		"Time":        float32(s.musicFrames() / ymFrameRate),
+		"BarCount":    float32(set.Bars),
+		"BarHeight":   float32(set.BarHeight * (1 + barPulse*s.clock.Pulse)),
+		"Flash":       float32(s.flash),
+	}
+	dst.DrawRectShader(w, h, s.shader, op)
+
+	safe := "OFF"
+	if s.config.SafeMode {
+		safe = "ON"
+	}
+	ebitenutil.DebugPrintAt(dst, fmt.Sprintf("COLOUR SET: %s  LEFT/RIGHT\nSAFE MODE: %s  S", set.Name, safe), 8, 8)
+}
+
+const colorShockShaderSrc = `//kage:unit pixels
+
+package main
+
+var Palette [16]vec4
+var PaletteSize float
+var Rotation float
+var LineHeight float
+var Time float
+var BarCount float
+var BarHeight float
+var Flash float
+
+func paletteAt(pos float) vec3 {
+	p := mod(pos, PaletteSize)
+	i := floor(p)
+	next := mod(i+1, PaletteSize)
+	var a vec3
+	var b vec3
+	for j := 0; j < 16; j++ {
+		if float(j) == i {
+			a = Palette[j].rgb
+		}
+		if float(j) == next {
+			b = Palette[j].rgb
+		}
+	}
+	return mix(a, b, p-i)
+}
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	line := floor(dstPos.y)
+	height := imageDstSize().y
+	col := paletteAt(floor(line/LineHeight)+Rotation) * 0.45
+
+	// Raster bars, each lit from the palette a couple of entries apart.
+	for i := 0; i < 8; i++ {
+		if float(i) >= BarCount {
+			break
+		}
+		center := height*0.5 + sin(Time*1.7+float(i)*0.6)*height*0.38
+		d := abs(line - center)
+		if d < BarHeight {
+			shade := cos(d / BarHeight * 1.5707963)
+			col = mix(col, paletteAt(float(i)*2+Rotation), shade)
+		}
+	}
+
+	return vec4(mix(col, vec3(1), Flash), 1)
+}
+`
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+// TestDefaultColorSets checks that every set in the embedded colors.json
+// survives preparation, since a bad one is only logged and skipped.
+func TestDefaultColorSets(t *testing.T) {
+	config, err := parseColorShockConfig(defaultColorSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.SafeMode {
+		t.Error("safe mode is off by default")
+	}
+	if got, want := len(config.Sets), 4; got != want {
+		t.Errorf("got %d colour sets, want %d", got, want)
+	}
+}
@@ -23,7 +23,9 @@ const (
 
 	bounceSpeed               = 7
 	scrollSpeed               = 8
+	scrollBoost               = 4
 	autoPilotActivateDuration = 60 * 60 * 2
 
-	sampleRate = 44100
+	defaultSampleRate = 44100
+	ymFrameRate       = 50
 )
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	creditsGlyphW      = 16
+	creditsGlyphH      = 16
+	creditsTextScale   = 2
+	creditsHeadScale   = 3
+	creditsLineSpacing = 8
+	creditsSpeed       = 1.0
+	creditsFade        = 96.0
+)
+
+// defaultCredits are rolled unless assets/credits has a credits.txt of
+// its own.
+//
+//go:embed credits.txt
+var defaultCredits string
+
+type creditKind int
+
+const (
+	creditText creditKind = iota
+	creditHeading
+	creditGap
+	creditLogo
+)
+
+type creditLine struct {
+	Kind  creditKind
+	Text  string
+	Lines int
+}
+
+// ParseCredits reads the credits markup, one entry per line:
+//
+//	# TEXT      a section heading
+//	@gap N      N empty lines
+//	@logo FILE  a picture from the credits asset directory
+//	; TEXT      a comment
+//
+// Any other line is centred text and a blank line is a single empty line.
+func ParseCredits(src string) []creditLine {
+	var lines []creditLine
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "#"):
+			lines = append(lines, creditLine{Kind: creditHeading, Text: strings.TrimSpace(line[1:])})
+		case strings.HasPrefix(line, "@gap"):
+			n, err := strconv.Atoi(strings.TrimSpace(line[len("@gap"):]))
+			if err != nil || n < 1 {
+				n = 1
+			}
+			lines = append(lines, creditLine{Kind: creditGap, Lines: n})
+		case strings.HasPrefix(line, "@logo"):
+			lines = append(lines, creditLine{Kind: creditLogo, Text: strings.TrimSpace(line[len("@logo"):])})
+		case strings.TrimSpace(line) == "":
+			lines = append(lines, creditLine{Kind: creditGap, Lines: 1})
+		default:
+			lines = append(lines, creditLine{Kind: creditText, Text: strings.TrimSpace(line)})
+		}
+	}
+	return lines
+}
+
+type creditItem struct {
+	line   creditLine
+	y      float64
+	height float64
+	logo   *ebiten.Image
+}
+
+type CreditsScreen struct {
+	font   *BitmapFont
+	items  []creditItem
+	height float64
+	offset float64
+}
+
+func newCreditsScreen(_ *Game) Screen {
+	src := defaultCredits
+	path := screenAssetPath("CREDITS", "credits.txt")
+	if data, err := os.ReadFile(path); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to read credits %s (%v), using defaults", path, err)
+		}
+	} else {
+		src = string(data)
+	}
+
+	s := &CreditsScreen{
+		font: loadBitmapFont(screenAssetPath("CREDITS", "font.png"), creditsGlyphW, creditsGlyphH),
+	}
+	s.layout(ParseCredits(src))
+	return s
+}
+
+func (s *CreditsScreen) layout(lines []creditLine) {
+	lineHeight := s.font.Height(creditsTextScale) + creditsLineSpacing
+	y := 0.0
+	for _, line := range lines {
+		item := creditItem{line: line, y: y}
+		switch line.Kind {
+		case creditText:
+			item.height = lineHeight
+		case creditHeading:
+			item.height = s.font.Height(creditsHeadScale) + creditsLineSpacing*2
+		case creditGap:
+			item.height = lineHeight * float64(line.Lines)
+		case creditLogo:
+			img, err := decodeImageFile(screenAssetPath("CREDITS", line.Text))
+			if err != nil {
+				log.Printf("skipping credits logo: %v", err)
+				continue
+			}
+			item.logo = ebiten.NewImageFromImage(img)
+			item.height = float64(item.logo.Bounds().Dy()) + creditsLineSpacing*2
+		}
+		s.items = append(s.items, item)
+		y += item.height
+	}
+	s.height = y
+}
+
+func (s *CreditsScreen) Update() error {
+	s.offset += creditsSpeed
+	// Loop once the last line has left the top of the screen.
+	if s.offset >= s.height+screenHeight {
+		s.offset = 0
+	}
+	return nil
+}
+
+func (s *CreditsScreen) Draw(dst *ebiten.Image) {
+	w := float64(dst.Bounds().Dx())
+	h := float64(dst.Bounds().Dy())
+	for _, item := range s.items {
+		y := math.Floor(h + item.y - s.offset)
+		if y+item.height < 0 || y > h {
+			continue
+		}
+
+		// Lines fade in from the bottom edge and out at the top.
+		alpha := math.Min((h-y)/creditsFade, (y+item.height)/creditsFade)
+		alpha = math.Max(0, math.Min(1, alpha))
+		var colors ebiten.ColorScale
+		colors.ScaleAlpha(float32(alpha))
+
+		switch item.line.Kind {
+		case creditText:
+			x := math.Floor((w - s.font.Width(item.line.Text, creditsTextScale)) / 2)
+			s.font.Draw(dst, item.line.Text, x, y, creditsTextScale, colors)
+		case creditHeading:
+			colors.Scale(1, 0.8, 0.3, 1)
+			x := math.Floor((w - s.font.Width(item.line.Text, creditsHeadScale)) / 2)
+			s.font.Draw(dst, item.line.Text, x, y+creditsLineSpacing, creditsHeadScale, colors)
+		case creditLogo:
+			var op ebiten.DrawImageOptions
+			op.ColorScale = colors
+			op.GeoM.Translate(math.Floor((w-float64(item.logo.Bounds().Dx()))/2), y+creditsLineSpacing)
+			dst.DrawImage(item.logo, &op)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCredits(t *testing.T) {
+	const src = "; written for the credits screen\n" +
+		"# CODING \n" +
+		"  NICK\r\n" +
+		"\n" +
+		"@gap 3\n" +
+		"@gap none\n" +
+		"@logo tcb.png\n" +
+		";# NOT A HEADING\n" +
+		"JAS\n"
+	want := []creditLine{
+		{Kind: creditHeading, Text: "CODING"},
+		{Kind: creditText, Text: "NICK"},
+		{Kind: creditGap, Lines: 1},
+		{Kind: creditGap, Lines: 3},
+		{Kind: creditGap, Lines: 1},
+		{Kind: creditLogo, Text: "tcb.png"},
+		{Kind: creditText, Text: "JAS"},
+	}
+	if got := ParseCredits(src); !slices.Equal(got, want) {
+		t.Errorf("ParseCredits =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseDefaultCredits(t *testing.T) {
+	var headings []string
+	for _, line := range ParseCredits(defaultCredits) {
+		if line.Kind == creditHeading {
+			headings = append(headings, line.Text)
+		}
+	}
+	want := []string{"THE CUDDLY DEMOS", "CODING", "GRAPHIXX", "MUZEXX", "GUEST SCREEN"}
+	if len(headings) != len(want) {
+		t.Fatalf("headings %q, want %q", headings, want)
+	}
+	for i := range want {
+		if headings[i] != want[i] {
+			t.Errorf("heading %d is %q, want %q", i, headings[i], want[i])
+		}
+	}
+}
@@ -33,6 +33,10 @@ var scrollerCharWidth = []int{
 	3, 1, 2, 3, 3, 3, 3, 1, 1, 1, 3, 2, 1, 2, 1, 2, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 3, 2, 3, 2, 3, 3, 2, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 3, 2, 3, 2, 3, 2, 2, 2, 2, 3, 3, 3, 2, 2, 3, 3, 3, 3, 3, 3,
 }
 
+// musicZones give parts of the map their own tune, crossfaded in when the
+// dude walks in and out of them.
+var musicZones = []MusicZone{}
+
 var demoScreens = []DemoScreen{
 	{X: 27, Y: 7, Name: "BIG_SPRITE"},
 	{X: 63, Y: 16, Name: "COLORSHOCK_II"},
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// EffectScreenDef describes a screen built from stacked generic layers
+// rather than bespoke code. Definitions live in screen.json inside the
+// door's asset directory.
+type EffectScreenDef struct {
+	Music  string           `json:"music"`
+	Layers []EffectLayerDef `json:"layers"`
+}
+
+// EffectLayerDef holds the parameters for one layer. Which fields are used
+// depends on Type: "plasma", "rotozoom", "scroller" or "sprites". Opacity
+// is 1 when left out; it is a pointer so an explicit 0 can hide a layer.
+type EffectLayerDef struct {
+	Type      string    `json:"type"`
+	Image     string    `json:"image"`
+	TileW     int       `json:"tileW"`
+	TileH     int       `json:"tileH"`
+	Text      string    `json:"text"`
+	Colors    []string  `json:"colors"`
+	Speed     float64   `json:"speed"`
+	Scale     float64   `json:"scale"`
+	Y         float64   `json:"y"`
+	Amplitude float64   `json:"amplitude"`
+	Count     int       `json:"count"`
+	Delay     float64   `json:"delay"`
+	Path      lissajous `json:"path"`
+	Opacity   *float64  `json:"opacity"`
+}
+
+func (d EffectLayerDef) opacity() float64 {
+	if d.Opacity == nil {
+		return 1
+	}
+	return *d.Opacity
+}
+
+type effectLayer interface {
+	Draw(dst *ebiten.Image, t float64)
+}
+
+type EffectScreen struct {
+	layers []effectLayer
+	music  string
+	time   float64
+}
+
+// effectPackScreen returns the factory for a door described by data.
+func effectPackScreen(name string) screenFactory {
+	return func(g *Game) Screen {
+		return newEffectScreen(g, name, loadEffectScreenDef(name))
+	}
+}
+
+func loadEffectScreenDef(name string) EffectScreenDef {
+	path := screenAssetPath(name, "screen.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("missing screen definition %s (%v), using default", path, err)
+		return defaultEffectScreenDef(name)
+	}
+	var def EffectScreenDef
+	if err := json.Unmarshal(data, &def); err != nil {
+		log.Printf("failed to parse %s (%v), using default", path, err)
+		return defaultEffectScreenDef(name)
+	}
+	return def
+}
+
+func defaultEffectScreenDef(name string) EffectScreenDef {
+	return EffectScreenDef{
+		Layers: []EffectLayerDef{
+			{Type: "plasma", Speed: 1, Scale: 1},
+			{Type: "scroller", Text: fmt.Sprintf("     %s     ", name), Y: scrollOffsetY, Speed: scrollSpeed},
+		},
+	}
+}
+
+func newEffectScreen(g *Game, name string, def EffectScreenDef) Screen {
+	s := &EffectScreen{}
+	for i, layerDef := range def.Layers {
+		layer, err := newEffectLayer(g, name, layerDef)
+		if err != nil {
+			log.Printf("%s layer %d: %v", name, i, err)
+			continue
+		}
+		s.layers = append(s.layers, layer)
+	}
+	if def.Music != "" {
+		s.music = screenAssetPath(name, def.Music)
+	}
+	return s
+}
+
+func newEffectLayer(g *Game, screen string, def EffectLayerDef) (effectLayer, error) {
+	if def.Speed == 0 {
+		def.Speed = 1
+	}
+	if def.Scale == 0 {
+		def.Scale = 1
+	}
+	switch def.Type {
+	case "plasma":
+		return newPlasmaLayer(def)
+	case "rotozoom":
+		img := loadImage(screenAssetPath(screen, def.Image), makePlaceholderRotoTexture)
+		return &rotozoomLayer{def: def, image: img}, nil
+	case "scroller":
+		scrollMap := BuildScrollMap(def.Text)
+		return &scrollerLayer{def: def, tiles: g.scrollTiles, scrollMap: scrollMap}, nil
+	case "sprites":
+		tiles := g.carebearTiles
+		if def.Image != "" {
+			img := loadImage(screenAssetPath(screen, def.Image), makePlaceholderCarebears)
+			tiles = NewTileSet(img, def.TileW, def.TileH)
+		}
+		if def.Count <= 0 {
+			def.Count = 1
+		}
+		if def.Path.Radius == 0 {
+			def.Path.Radius = 1
+		}
+		return &spritePathLayer{def: def, tiles: tiles}, nil
+	default:
+		return nil, fmt.Errorf("unknown layer type %q", def.Type)
+	}
+}
+
+func (s *EffectScreen) Update() error {
+	s.time += 1.0 / 60.0
+	return nil
+}
+
+func (s *EffectScreen) Draw(dst *ebiten.Image) {
+	for _, layer := range s.layers {
+		layer.Draw(dst, s.time)
+	}
+}
+
+func (s *EffectScreen) Music() string {
+	return s.music
+}
+
+const (
+	plasmaWidth  = screenWidth / 4
+	plasmaHeight = screenHeight / 4
+)
+
+// plasmaLayer computes a classic sine plasma at quarter resolution and
+// stretches it over the screen.
+type plasmaLayer struct {
+	def     EffectLayerDef
+	image   *ebiten.Image
+	pixels  []byte
+	palette [256]color.RGBA
+}
+
+func newPlasmaLayer(def EffectLayerDef) (effectLayer, error) {
+	l := &plasmaLayer{
+		def:    def,
+		image:  ebiten.NewImage(plasmaWidth, plasmaHeight),
+		pixels: make([]byte, plasmaWidth*plasmaHeight*4),
+	}
+	if len(def.Colors) == 0 {
+		for i := range l.palette {
+			f := float64(i) / 256 * 2 * math.Pi
+			l.palette[i] = color.RGBA{
+				R: uint8(128 + 127*math.Sin(f)),
+				G: uint8(128 + 127*math.Sin(f+2*math.Pi/3)),
+				B: uint8(128 + 127*math.Sin(f+4*math.Pi/3)),
+				A: 255,
+			}
+		}
+		return l, nil
+	}
+
+	stops := make([]color.RGBA, len(def.Colors))
+	for i, hex := range def.Colors {
+		col, err := parseHexColor(hex)
+		if err != nil {
+			return nil, err
+		}
+		stops[i] = col
+	}
+	for i := range l.palette {
+		p := float64(i) / 256 * float64(len(stops))
+		a := stops[int(p)%len(stops)]
+		b := stops[(int(p)+1)%len(stops)]
+		f := p - math.Floor(p)
+		l.palette[i] = color.RGBA{
+			R: uint8(float64(a.R) + (float64(b.R)-float64(a.R))*f),
+			G: uint8(float64(a.G) + (float64(b.G)-float64(a.G))*f),
+			B: uint8(float64(a.B) + (float64(b.B)-float64(a.B))*f),
+			A: 255,
+		}
+	}
+	return l, nil
+}
+
+func (l *plasmaLayer) Draw(dst *ebiten.Image, t float64) {
+	t *= l.def.Speed
+	scale := 0.06 / l.def.Scale
+	alpha := uint8(255 * l.def.opacity())
+	for y := 0; y < plasmaHeight; y++ {
+		fy := float64(y) * scale
+		for x := 0; x < plasmaWidth; x++ {
+			fx := float64(x) * scale
+			v := math.Sin(fx+t) +
+				math.Sin((fy+t)*0.5) +
+				math.Sin((fx+fy+t)*0.5) +
+				math.Sin(math.Sqrt(fx*fx+fy*fy)+t*1.3)
+			col := l.palette[uint8(int((v+4)*32))]
+			i := (y*plasmaWidth + x) * 4
+			l.pixels[i] = uint8(uint16(col.R) * uint16(alpha) / 255)
+			l.pixels[i+1] = uint8(uint16(col.G) * uint16(alpha) / 255)
+			l.pixels[i+2] = uint8(uint16(col.B) * uint16(alpha) / 255)
+			l.pixels[i+3] = alpha
+		}
+	}
+	l.image.WritePixels(l.pixels)
+
+	var op ebiten.DrawImageOptions
+	op.GeoM.Scale(float64(dst.Bounds().Dx())/plasmaWidth, float64(dst.Bounds().Dy())/plasmaHeight)
+	dst.DrawImage(l.image, &op)
+}
+
+// rotozoomLayer tiles a picture over the screen while rotating and zooming.
+type rotozoomLayer struct {
+	def   EffectLayerDef
+	image *ebiten.Image
+}
+
+func (l *rotozoomLayer) Draw(dst *ebiten.Image, t float64) {
+	t *= l.def.Speed
+	w := float32(dst.Bounds().Dx())
+	h := float32(dst.Bounds().Dy())
+	angle := t * 0.7
+	zoom := l.def.Scale * (1.5 + math.Sin(t*0.9))
+	s, c := math.Sincos(-angle)
+	srcCenterX := float64(l.image.Bounds().Dx())/2 + t*40
+	srcCenterY := float64(l.image.Bounds().Dy())/2 + t*25
+
+	// Map each screen corner back into the texture.
+	corner := func(x, y float32) ebiten.Vertex {
+		dx := (float64(x) - float64(w)/2) / zoom
+		dy := (float64(y) - float64(h)/2) / zoom
+		return ebiten.Vertex{
+			DstX:   x,
+			DstY:   y,
+			SrcX:   float32(srcCenterX + dx*c - dy*s),
+			SrcY:   float32(srcCenterY + dx*s + dy*c),
+			ColorR: 1,
+			ColorG: 1,
+			ColorB: 1,
+			ColorA: float32(l.def.opacity()),
+		}
+	}
+	vertices := []ebiten.Vertex{corner(0, 0), corner(w, 0), corner(0, h), corner(w, h)}
+	indices := []uint16{0, 1, 2, 1, 3, 2}
+	op := &ebiten.DrawTrianglesOptions{Address: ebiten.AddressRepeat}
+	dst.DrawTriangles(vertices, indices, l.image, op)
+}
+
+// scrollerLayer scrolls text in the chrome font along a sine wave.
+type scrollerLayer struct {
+	def       EffectLayerDef
+	tiles     *TileSet
+	scrollMap []int
+}
+
+func (l *scrollerLayer) Draw(dst *ebiten.Image, t float64) {
+	if len(l.scrollMap) == 0 {
+		return
+	}
+	length := len(l.scrollMap) * l.tiles.TileW
+	offset := int(t*60*l.def.Speed) % length
+	first := offset / l.tiles.TileW
+	shift := offset % l.tiles.TileW
+	columns := dst.Bounds().Dx()/l.tiles.TileW + 2
+
+	var op ebiten.DrawImageOptions
+	op.ColorScale.ScaleAlpha(float32(l.def.opacity()))
+	for i := 0; i < columns; i++ {
+		x := float64(i*l.tiles.TileW - shift)
+		y := l.def.Y + math.Sin(t*3+x*0.01*l.def.Scale)*l.def.Amplitude
+		op.GeoM.Reset()
+		op.GeoM.Translate(x, math.Floor(y))
+		dst.DrawImage(l.tiles.Tile(l.scrollMap[(first+i)%len(l.scrollMap)]), &op)
+	}
+}
+
+// spritePathLayer moves a chain of sprites along a Lissajous path.
+type spritePathLayer struct {
+	def   EffectLayerDef
+	tiles *TileSet
+}
+
+func (l *spritePathLayer) Draw(dst *ebiten.Image, t float64) {
+	t *= l.def.Speed
+	w := float64(dst.Bounds().Dx())
+	h := float64(dst.Bounds().Dy())
+	tileW := float64(l.tiles.TileW)
+	tileH := float64(l.tiles.TileH)
+	radiusX := (w - tileW) * 0.5 * l.def.Scale
+	radiusY := (h - tileH) * 0.5 * l.def.Scale
+
+	var op ebiten.DrawImageOptions
+	op.ColorScale.ScaleAlpha(float32(l.def.opacity()))
+	for i := l.def.Count - 1; i >= 0; i-- {
+		x, y := l.def.Path.Point(t-float64(i)*l.def.Delay, radiusX, radiusY)
+		op.GeoM.Reset()
+		op.GeoM.Translate(math.Floor(w/2-tileW/2+x), math.Floor(h/2-tileH/2+y))
+		dst.DrawImage(l.tiles.Tile(i), &op)
+	}
+}
+
+func makePlaceholderRotoTexture() *ebiten.Image {
+	const size = 64
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			col := color.RGBA{40, 40, 120, 255}
+			if (x/16+y/16)%2 == 0 {
+				col = color.RGBA{220, 200, 60, 255}
+			}
+			img.Set(x, y, col)
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEffectLayerOpacity(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		want float64
+	}{
+		{`{"type": "plasma"}`, 1},
+		{`{"type": "plasma", "opacity": 0}`, 0},
+		{`{"type": "plasma", "opacity": 0.35}`, 0.35},
+	} {
+		var def EffectLayerDef
+		if err := json.Unmarshal([]byte(tc.json), &def); err != nil {
+			t.Fatal(err)
+		}
+		if got := def.opacity(); got != tc.want {
+			t.Errorf("%s: opacity %g, want %g", tc.json, got, tc.want)
+		}
+	}
+}
+
+// TestShippedScreens loads every screen.json under assets and checks that
+// the files it names are there, since a missing one only shows up as a
+// placeholder or silence when the door is opened.
+func TestShippedScreens(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "assets", "*", "screen.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Skip("no screens shipped")
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var def EffectScreenDef
+		if err := json.Unmarshal(data, &def); err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		dir := filepath.Dir(path)
+		exists := func(what, name string) {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("%s: %s: %v", path, what, err)
+			}
+		}
+		if def.Music == "" {
+			t.Errorf("%s: no music", path)
+		} else {
+			exists("music", def.Music)
+		}
+		for i, layer := range def.Layers {
+			if layer.Image == "" {
+				continue
+			}
+			exists("layer image", layer.Image)
+			if layer.Type == "sprites" && (layer.TileW <= 0 || layer.TileH <= 0) {
+				t.Errorf("%s: sprites layer %d has no tile size", path, i)
+			}
+		}
+	}
+}
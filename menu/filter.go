@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// OutputFilter selects how the chip output is coloured before it is heard.
+// The chip emulation already removes most DC; these model what comes after
+// it on the way to the listener.
+type OutputFilter int
+
+const (
+	// FilterOff leaves the output as rendered.
+	FilterOff OutputFilter = iota
+	// FilterDC only takes out what DC offset is left.
+	FilterDC
+	// FilterST adds the low-pass of the ST's audio output, which takes the
+	// edge off the square waves.
+	FilterST
+	// FilterTV narrows the sound to a small TV speaker.
+	FilterTV
+	outputFilters
+)
+
+func (f OutputFilter) String() string {
+	switch f {
+	case FilterDC:
+		return "DC"
+	case FilterST:
+		return "ST"
+	case FilterTV:
+		return "TV"
+	default:
+		return "OFF"
+	}
+}
+
+func ParseOutputFilter(s string) (OutputFilter, error) {
+	for f := FilterOff; f < outputFilters; f++ {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	return FilterOff, fmt.Errorf("filter %q: want off, dc, st or tv", s)
+}
+
+// stages returns the filters of a profile, in the order they are applied.
+func (f OutputFilter) stages(sampleRate int) []biquad {
+	rate := float64(sampleRate)
+	switch f {
+	case FilterDC:
+		return []biquad{highpass(20, math.Sqrt2/2, rate)}
+	case FilterST:
+		return []biquad{highpass(20, math.Sqrt2/2, rate), lowpass(8000, math.Sqrt2/2, rate)}
+	case FilterTV:
+		// A small speaker has no bass, rolls off early and peaks a little
+		// where it does play.
+		return []biquad{highpass(180, math.Sqrt2/2, rate), lowpass(4500, 1.2, rate)}
+	}
+	return nil
+}
+
+// maxCutoff is the highest cutoff a filter gets, as a fraction of the
+// sample rate. The cookbook formulas fold back at Nyquist, so at low rates
+// the profiles' cutoffs are brought down to just below it.
+const maxCutoff = 0.45
+
+// biquad is a second-order IIR filter from the Audio EQ Cookbook.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+func lowpass(freq, q, rate float64) biquad {
+	w := 2 * math.Pi * min(freq, rate*maxCutoff) / rate
+	alpha, cos := math.Sin(w)/(2*q), math.Cos(w)
+	return newBiquad((1-cos)/2, 1-cos, (1-cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+func highpass(freq, q, rate float64) biquad {
+	w := 2 * math.Pi * min(freq, rate*maxCutoff) / rate
+	alpha, cos := math.Sin(w)/(2*q), math.Cos(w)
+	return newBiquad((1+cos)/2, -(1 + cos), (1+cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+func (b *biquad) next(x float64) float64 {
+	y := b.b0*x + b.b1*b.x1 + b.b2*b.x2 - b.a1*b.y1 - b.a2*b.y2
+	b.x2, b.x1 = b.x1, x
+	b.y2, b.y1 = b.y1, y
+	return y
+}
+
+// outputDSP runs a filter profile over rendered samples, keeping separate
+// state for the left and right side.
+type outputDSP struct {
+	filter OutputFilter
+	sides  [2][]biquad
+}
+
+func (d *outputDSP) set(f OutputFilter, sampleRate int) {
+	d.filter = f
+	d.sides = [2][]biquad{f.stages(sampleRate), f.stages(sampleRate)}
+}
+
+// process filters buf in place. Stereo buffers hold left and right
+// samples interleaved; mono ones only use the left side's state.
+func (d *outputDSP) process(buf []int16, stereo bool) {
+	if d.filter == FilterOff {
+		return
+	}
+	channels := 1
+	if stereo {
+		channels = 2
+	}
+	for i, v := range buf {
+		stages := d.sides[i%channels]
+		x := float64(v)
+		for s := range stages {
+			x = stages[s].next(x)
+		}
+		buf[i] = int16(min(max(x, -32768), 32767))
+	}
+}
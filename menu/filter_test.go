@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestFilterLowRates plays a 200 Hz tone through every profile at rates
+// down to 8000 Hz, where the profiles' cutoffs are above Nyquist, and checks
+// that it comes out at about the level it went in.
+func TestFilterLowRates(t *testing.T) {
+	const freq, level = 200, 8000
+	for _, rate := range []int{8000, 16000, 22050, 44100} {
+		for f := FilterDC; f < outputFilters; f++ {
+			var dsp outputDSP
+			dsp.set(f, rate)
+			buf := make([]int16, rate)
+			for i := range buf {
+				buf[i] = int16(level * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+			}
+			dsp.process(buf, false)
+
+			peak := 0.0
+			for _, v := range buf[rate/2:] {
+				peak = max(peak, math.Abs(float64(v)))
+			}
+			if peak < level/2 || peak > level*2 {
+				t.Errorf("%s at %d Hz: a %d Hz tone at %d came out at %.0f", f, rate, freq, level, peak)
+			}
+		}
+	}
+}
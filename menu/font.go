@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	fontFirstChar = 32
+	fontColumns   = 16
+
+	placeholderGlyphW = 6
+	placeholderGlyphH = 16
+)
+
+// BitmapFont draws text from a sheet of fixed-size glyphs laid out in ASCII
+// order from the space character, sixteen to a row.
+type BitmapFont struct {
+	Glyphs *TileSet
+}
+
+func NewBitmapFont(img *ebiten.Image, glyphW, glyphH int) *BitmapFont {
+	return &BitmapFont{Glyphs: NewTileSet(img, glyphW, glyphH)}
+}
+
+// loadBitmapFont loads a glyph sheet, falling back to the built-in debug
+// font when the sheet is missing.
+func loadBitmapFont(path string, glyphW, glyphH int) *BitmapFont {
+	img, err := decodeImageFile(path)
+	if err != nil {
+		log.Printf("%v, using placeholder font", err)
+		return NewBitmapFont(makePlaceholderFont(), placeholderGlyphW, placeholderGlyphH)
+	}
+	return NewBitmapFont(ebiten.NewImageFromImage(img), glyphW, glyphH)
+}
+
+func (f *BitmapFont) Width(text string, scale float64) float64 {
+	return float64(len(text)*f.Glyphs.TileW) * scale
+}
+
+func (f *BitmapFont) Height(scale float64) float64 {
+	return float64(f.Glyphs.TileH) * scale
+}
+
+// Draw renders text with its top-left corner at x, y, tinted by colors.
+func (f *BitmapFont) Draw(dst *ebiten.Image, text string, x, y, scale float64, colors ebiten.ColorScale) {
+	var op ebiten.DrawImageOptions
+	op.ColorScale = colors
+	for i := 0; i < len(text); i++ {
+		c := int(text[i]) - fontFirstChar
+		if c <= 0 || c >= len(f.Glyphs.Tiles) {
+			continue
+		}
+		op.GeoM.Reset()
+		op.GeoM.Scale(scale, scale)
+		op.GeoM.Translate(x+float64(i*f.Glyphs.TileW)*scale, y)
+		dst.DrawImage(f.Glyphs.Tile(c), &op)
+	}
+}
+
+// makePlaceholderFont renders the built-in debug font into a glyph sheet.
+func makePlaceholderFont() *ebiten.Image {
+	const rows = 6
+	img := ebiten.NewImage(fontColumns*placeholderGlyphW, rows*placeholderGlyphH)
+	for i := 0; i < fontColumns*rows; i++ {
+		ch := string(rune(fontFirstChar + i))
+		// DebugPrintAt draws one pixel to the right of the given position.
+		ebitenutil.DebugPrintAt(img, ch, (i%fontColumns)*placeholderGlyphW-1, (i/fontColumns)*placeholderGlyphH)
+	}
+	return img
+}
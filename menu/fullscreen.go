@@ -0,0 +1,114 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// The ST displays 320x200 pixels inside its borders; the menu runs at twice
+// that resolution, so the normal display area is 640x400 in screen pixels.
+const (
+	stDisplayWidth  = 640
+	stDisplayHeight = 400
+	stBorderX       = (screenWidth - stDisplayWidth) / 2
+	stBorderY       = (screenHeight - stDisplayHeight) / 2
+)
+
+const fullscreenScrollText = `     NO BORDERS LEFT!   THIS SCREEN USES EVERY PIXEL OF THE OVERSCAN AREA, TOP, BOTTOM, LEFT AND RIGHT.   PRESS B TO SEE WHERE THE BORDERS USED TO BE...     `
+
+type FullscreenScreen struct {
+	picture      *ebiten.Image
+	scroller     *TileMap
+	scrollLength int
+	scrollX      int
+	time         float64
+	showBorders  bool
+}
+
+func newFullscreenScreen(g *Game) Screen {
+	scrollMap := BuildScrollMap(fullscreenScrollText)
+	return &FullscreenScreen{
+		picture:      loadImage(screenAssetPath("FULLSCREEN", "picture.png"), makePlaceholderOverscanPicture),
+		scroller:     NewTileMap([][]int{scrollMap}, g.scrollTiles),
+		scrollLength: len(scrollMap) * scrollTileW,
+	}
+}
+
+func (s *FullscreenScreen) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		s.showBorders = !s.showBorders
+	}
+	s.time += 1.0 / 60.0
+	s.scrollX += scrollSpeed
+	if s.scrollLength > scrollWidth {
+		s.scrollX %= s.scrollLength - scrollWidth
+	}
+	return nil
+}
+
+func (s *FullscreenScreen) Draw(dst *ebiten.Image) {
+	// Stretch the picture over the whole screen, borders included.
+	b := s.picture.Bounds()
+	var op ebiten.DrawImageOptions
+	op.GeoM.Scale(float64(screenWidth)/float64(b.Dx()), float64(screenHeight)/float64(b.Dy()))
+	dst.DrawImage(s.picture, &op)
+
+	// The scroller wobbles through the bottom border where the menu
+	// scroller normally lives.
+	wobble := int(math.Sin(s.time*2) * 8)
+	s.scroller.Draw(dst, s.scrollX, 0, scrollOffsetX, scrollOffsetY+wobble, scrollWidth, scrollHeight)
+
+	if s.showBorders {
+		s.drawBorders(dst)
+	}
+}
+
+func (s *FullscreenScreen) drawBorders(dst *ebiten.Image) {
+	shade := color.RGBA{0, 0, 0, 140}
+	ebitenutil.DrawRect(dst, 0, 0, screenWidth, stBorderY, shade)
+	ebitenutil.DrawRect(dst, 0, stBorderY+stDisplayHeight, screenWidth, screenHeight-stBorderY-stDisplayHeight, shade)
+	ebitenutil.DrawRect(dst, 0, stBorderY, stBorderX, stDisplayHeight, shade)
+	ebitenutil.DrawRect(dst, stBorderX+stDisplayWidth, stBorderY, screenWidth-stBorderX-stDisplayWidth, stDisplayHeight, shade)
+
+	edge := color.RGBA{255, 255, 0, 255}
+	left := float64(stBorderX)
+	top := float64(stBorderY)
+	right := float64(stBorderX + stDisplayWidth)
+	bottom := float64(stBorderY + stDisplayHeight)
+	ebitenutil.DrawLine(dst, left, top, right, top, edge)
+	ebitenutil.DrawLine(dst, left, bottom, right, bottom, edge)
+	ebitenutil.DrawLine(dst, left, top, left, bottom, edge)
+	ebitenutil.DrawLine(dst, right, top, right, bottom, edge)
+
+	ebitenutil.DebugPrintAt(dst, "TOP BORDER", stBorderX+4, stBorderY-20)
+	ebitenutil.DebugPrintAt(dst, "BOTTOM BORDER", stBorderX+4, stBorderY+stDisplayHeight+4)
+	ebitenutil.DebugPrintAt(dst, "320X200 DISPLAY", stBorderX+4, stBorderY+4)
+}
+
+func makePlaceholderOverscanPicture() *ebiten.Image {
+	const (
+		w = screenWidth / 2
+		h = screenHeight / 2
+	)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx := float64(x - w/2)
+			dy := float64(y - h/2)
+			ring := int(math.Sqrt(dx*dx+dy*dy)/12) % 2
+			check := (x/16 + y/16) % 2
+			img.Set(x, y, color.RGBA{
+				R: uint8(40 + 150*ring),
+				G: uint8(30 + y*200/h),
+				B: uint8(90 + 100*check),
+				A: 255,
+			})
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
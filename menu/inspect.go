@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ymAttributeNames names the attribute bits of YM5 and YM6 headers.
+var ymAttributeNames = []struct {
+	bit  uint32
+	name string
+}{
+	{ymAttrInterleaved, "interleaved"},
+	{ymAttrDrumSigned, "signed drums"},
+	{ymAttrDrum4Bits, "4-bit drums"},
+}
+
+// inspectCommand prints what the menu's loader makes of a YM file and can
+// dump its register stream, to check tunes before they go in the playlist.
+//
+//	menu inspect [-dump csv|json] [-o out] in.ym
+func inspectCommand(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	dump := flags.String("dump", "", "dump the register stream frame by frame: csv or json")
+	output := flags.String("o", "", "write the dump to a file instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: menu inspect [flags] in.ym")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("inspect needs one YM file")
+	}
+	if *dump != "" && *dump != "csv" && *dump != "json" {
+		return fmt.Errorf("unknown dump format %q", *dump)
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	player, err := NewYMPlayer(data, defaultSampleRate, false)
+	if err != nil {
+		return err
+	}
+	defer player.Close()
+	file := player.file
+
+	if *dump == "" {
+		printYMInfo(os.Stdout, flags.Arg(0), data, player)
+		return nil
+	}
+	if file == nil || file.Frames == 0 {
+		return errors.New("the file has no register stream to dump")
+	}
+	out := io.Writer(os.Stdout)
+	var f *os.File
+	if *output != "" {
+		if f, err = os.Create(*output); err != nil {
+			return err
+		}
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	if *dump == "csv" {
+		err = dumpRegistersCSV(w, file)
+	} else {
+		err = dumpRegistersJSON(w, file, player.Info())
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	// The file is only known to be written once it is closed.
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func printYMInfo(w io.Writer, path string, data []byte, player *YMPlayer) {
+	info := player.Info()
+	file := player.file
+	packed := ""
+	if isYM(data) && !strings.HasPrefix(string(data), "YM") {
+		packed = " (LHA packed)"
+	}
+
+	fmt.Fprintf(w, "file        %s%s\n", path, packed)
+	fmt.Fprintf(w, "title       %s\n", info.Title)
+	fmt.Fprintf(w, "author      %s\n", info.Author)
+	fmt.Fprintf(w, "comment     %s\n", info.Comment)
+	fmt.Fprintf(w, "type        %s\n", info.Format)
+	fmt.Fprintf(w, "duration    %s\n", formatDuration(info.Duration))
+	if file == nil {
+		reason := ""
+		if _, err := ParseYMFile(data); err != nil {
+			reason = " (" + err.Error() + ")"
+		}
+		fmt.Fprintf(w, "format      not readable by the menu's parser%s, played by stsound only\n", reason)
+		return
+	}
+
+	engine := "stsound"
+	if ayEngineSupports(file) {
+		engine = "AY emulation"
+	}
+	fmt.Fprintf(w, "format      %s, played by %s\n", file.Format, engine)
+	fmt.Fprintf(w, "frames      %d at %d Hz\n", file.Frames, file.FrameRate)
+	loopAt := time.Duration(0)
+	if file.FrameRate > 0 {
+		loopAt = time.Duration(file.LoopFrame) * time.Second / time.Duration(file.FrameRate)
+	}
+	fmt.Fprintf(w, "loop frame  %d (%s)\n", file.LoopFrame, formatDuration(loopAt))
+	fmt.Fprintf(w, "clock       %d Hz\n", file.Clock)
+	fmt.Fprintf(w, "attributes  %#08x%s\n", file.Attributes, attributeNames(file.Attributes))
+	fmt.Fprintf(w, "digidrums   %d\n", len(file.DigiDrums))
+	for i, drum := range file.DigiDrums {
+		fmt.Fprintf(w, "  %2d        %d bytes\n", i, len(drum))
+	}
+
+	for _, warning := range ymWarnings(file) {
+		fmt.Fprintf(w, "warning     %s\n", warning)
+	}
+}
+
+func attributeNames(attributes uint32) string {
+	var names []string
+	for _, a := range ymAttributeNames {
+		if attributes&a.bit != 0 {
+			names = append(names, a.name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(names, ", ") + ")"
+}
+
+// ymWarnings lists what would make a tune play badly in the menu.
+func ymWarnings(f *YMFile) []string {
+	var warnings []string
+	if f.Frames == 0 {
+		warnings = append(warnings, "no register frames")
+	}
+	if f.FrameRate > 0 && defaultSampleRate%f.FrameRate != 0 {
+		warnings = append(warnings, fmt.Sprintf("%d Hz frames drift at %d Hz", f.FrameRate, defaultSampleRate))
+	}
+	if f.Clock != ayClock {
+		warnings = append(warnings, fmt.Sprintf("clock is not the ST's %d Hz", ayClock))
+	}
+	return warnings
+}
+
+func dumpRegistersCSV(w io.Writer, f *YMFile) error {
+	header := []string{"frame"}
+	for reg := 0; reg < f.registers; reg++ {
+		header = append(header, fmt.Sprintf("r%d", reg))
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(header, ",")); err != nil {
+		return err
+	}
+	for frame := 0; frame < f.Frames; frame++ {
+		fmt.Fprintf(w, "%d", frame)
+		for reg := 0; reg < f.registers; reg++ {
+			fmt.Fprintf(w, ",%d", f.Register(frame, reg))
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ymDump is the JSON form of a register dump.
+type ymDump struct {
+	Format     string  `json:"format"`
+	Title      string  `json:"title"`
+	Author     string  `json:"author"`
+	Frames     int     `json:"frames"`
+	FrameRate  int     `json:"frameRate"`
+	LoopFrame  int     `json:"loopFrame"`
+	Clock      int     `json:"clock"`
+	Attributes uint32  `json:"attributes"`
+	DigiDrums  []int   `json:"digiDrums"`
+	Registers  [][]int `json:"registers"`
+}
+
+func dumpRegistersJSON(w io.Writer, f *YMFile, info SourceInfo) error {
+	dump := ymDump{
+		Format:     f.Format,
+		Title:      info.Title,
+		Author:     info.Author,
+		Frames:     f.Frames,
+		FrameRate:  f.FrameRate,
+		LoopFrame:  f.LoopFrame,
+		Clock:      f.Clock,
+		Attributes: f.Attributes,
+		DigiDrums:  []int{},
+		Registers:  make([][]int, f.Frames),
+	}
+	for _, drum := range f.DigiDrums {
+		dump.DigiDrums = append(dump.DigiDrums, len(drum))
+	}
+	for frame := range dump.Registers {
+		regs := make([]int, f.registers)
+		for reg := range regs {
+			regs[reg] = int(f.Register(frame, reg))
+		}
+		dump.Registers[frame] = regs
+	}
+	return json.NewEncoder(w).Encode(dump)
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -46,14 +47,16 @@ type AutoPilot struct {
 }
 
 type DemoScreen struct {
-	X    int
-	Y    int
-	Name string
+	X     int
+	Y     int
+	Name  string
+	Music string
 }
 
 type LoaderState struct {
 	Active     bool
 	ScreenName string
+	Music      string
 	Timer      int
 }
 
@@ -69,6 +72,16 @@ type Game struct {
 	audioContext *audio.Context
 	audioPlayer  *audio.Player
 	ymPlayer     *YMPlayer
+	menuSource   MusicSource
+	music        *PlaylistStream
+	mixer        *Mixer
+	menuChannel  *MixerChannel
+	sfx          *SFX
+	audioConfig  AudioConfig
+	underruns    underrunWatch
+
+	musicGeneration int
+	jukebox         bool
 
 	mapTiles      *TileSet
 	dudeTiles     *TileSet
@@ -89,6 +102,13 @@ type Game struct {
 	model        Model
 	autoPilot    AutoPilot
 	loading      LoaderState
+	screen       ScreenState
+	nowPlaying   NowPlaying
+	areaMusic    AreaMusic
+	voices       VoiceControl
+	clock        MusicClock
+	latency      time.Duration
+	visualiser   Visualiser
 	thrustOff    int
 	simTime      float64
 	carebearTime float64
@@ -99,12 +119,13 @@ type Game struct {
 
 var bouncingAnimation = []int{0, 3, 5, 6, 5, 3, 0, 1, 2, 3, 2, 1, 0}
 
-func NewGame() *Game {
+func NewGame(audioConfig AudioConfig) *Game {
 	maxTile := maxTileIndex(cuddlyMap)
 	assets := LoadAssets(filepath.Join("assets", "menu"), maxTile)
 
 	g := &Game{
 		assets:       assets,
+		audioConfig:  audioConfig,
 		useCRT:       false,
 		gameCanvas:   ebiten.NewImage(gameWidth, gameHeight),
 		screenCanvas: ebiten.NewImage(screenWidth, screenHeight),
@@ -121,7 +142,7 @@ func NewGame() *Game {
 	g.scrollerLength = len(scrollMap) * scrollTileW
 
 	g.background = g.buildBackground()
-	g.sineSprites = &SineSprites{Tiles: g.carebearTiles}
+	g.sineSprites = NewSineSprites(loadSineConfig(filepath.Join("assets", "menu", sineConfig)), g.carebearTiles)
 
 	g.animations = DudeAnimations{
 		MoveRight:   Animation{Duration: 0.35, Indices: []int{2, 3, 4, 5, 6, 7, 8, 9}, Loop: true},
@@ -155,24 +176,52 @@ func (g *Game) Reset() {
 }
 
 func (g *Game) initAudio() {
-	g.audioContext = audio.NewContext(sampleRate)
-	if len(g.assets.MenuYM) == 0 {
+	rate := g.audioConfig.SampleRate
+	g.audioContext = audio.NewContext(rate)
+	g.voices.Panning = MonoPanning
+	var tracks []Track
+	if len(g.assets.MenuYM) > 0 {
+		tracks = append(tracks, Track{Path: "menu.ym", Data: g.assets.MenuYM})
+	}
+	extra, err := LoadPlaylist(musicDir)
+	if err != nil {
+		log.Printf("no playlist (%v): only the menu tune is available", err)
+	}
+	tracks = append(tracks, extra...)
+	if len(tracks) == 0 {
 		return
 	}
-	var err error
-	g.ymPlayer, err = NewYMPlayer(g.assets.MenuYM, sampleRate, true)
+
+	// The menu tune loops until jukebox mode is switched on.
+	playlist := NewPlaylist(tracks)
+	playlist.Repeat = RepeatOne
+	g.music, err = NewPlaylistStream(playlist, rate)
 	if err != nil {
 		log.Printf("failed to create YM player: %v", err)
 		return
 	}
-	g.audioPlayer, err = g.audioContext.NewPlayer(g.ymPlayer)
+	source, generation := g.music.Current()
+	g.setMenuSource(source)
+	g.musicGeneration = generation
+	g.mixer = NewMixer(rate)
+	g.audioPlayer, err = g.audioContext.NewPlayer(g.mixer)
 	if err != nil {
 		log.Printf("failed to create audio player: %v", err)
-		g.ymPlayer.Close()
+		g.music.Close()
+		g.music = nil
+		g.mixer = nil
 		g.ymPlayer = nil
+		g.menuSource = nil
 		return
 	}
+	if g.audioConfig.Buffer > 0 {
+		g.audioPlayer.SetBufferSize(g.audioConfig.Buffer)
+	}
+	g.menuChannel = g.mixer.Add(g.music, 1)
+	g.sfx = NewSFX(g.mixer, sfxDir, rate)
+	g.areaMusic.menu = 1
 	g.audioPlayer.Play()
+	g.nowPlaying.Show(source.Info())
 }
 
 func (g *Game) initShader() {
@@ -191,6 +240,23 @@ func (g *Game) Update() error {
 	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
 		g.useCRT = !g.useCRT
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		g.nowPlaying.Toggle()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) && g.sfx != nil {
+		g.sfx.SetOn(!g.sfx.On)
+	}
+	g.updateMusic()
+	g.updateAreaMusic()
+	g.updateVoices()
+	g.updateMusicClock()
+	g.checkUnderruns()
+	g.updateVisualiser()
+	g.nowPlaying.Update()
+
+	if g.screen.Active != nil {
+		return g.updateScreen()
+	}
 
 	if g.loading.Active {
 		g.updateLoading()
@@ -215,8 +281,10 @@ func (g *Game) Update() error {
 		}
 	}
 
+	before := g.model
 	g.integrate(left, right, thrust)
-	g.carebearTime += 1.0 / 60.0
+	g.updateSFX(before)
+	g.carebearTime += g.clock.Delta
 	g.simTime += 1.0 / 60.0
 	g.handleLoad(load)
 
@@ -224,7 +292,14 @@ func (g *Game) Update() error {
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	g.drawScene(g.screenCanvas)
+	if g.screen.Active != nil {
+		g.screenCanvas.Fill(color.Black)
+		g.screen.Active.Draw(g.screenCanvas)
+	} else {
+		g.drawScene(g.screenCanvas)
+	}
+	g.nowPlaying.Draw(g.screenCanvas)
+	g.drawRegisterWindow(g.screenCanvas)
 	if g.useCRT && g.crtShader != nil {
 		op := &ebiten.DrawRectShaderOptions{}
 		op.Images[0] = g.screenCanvas
@@ -410,7 +485,7 @@ func (g *Game) integrate(left, right, thrust bool) {
 		m.Position.Y = float64(mapHeight - dudeSize)
 	}
 
-	m.ScrollerPosition += scrollSpeed
+	m.ScrollerPosition += scrollSpeed + g.musicBoost()
 }
 
 func (g *Game) haveLanded(m *Model) bool {
@@ -458,14 +533,13 @@ func (g *Game) startLoading(name string) {
 	g.loading = LoaderState{
 		Active:     true,
 		ScreenName: name,
+		Music:      doorMusic(name),
 		Timer:      120,
 	}
+	g.sfx.Play("door")
 	g.autoPilot.NowLoadScreen = false
 	g.autoPilot.WaitToLoad = 80
 	g.advanceAutoPilot()
-	if g.audioPlayer != nil {
-		g.audioPlayer.Pause()
-	}
 }
 
 func (g *Game) advanceAutoPilot() {
@@ -484,9 +558,7 @@ func (g *Game) updateLoading() {
 	g.loading.Active = false
 	g.autoPilot.NowLoadScreen = false
 	g.autoPilot.WaitToLoad = 80
-	if g.audioPlayer != nil && !g.audioPlayer.IsPlaying() {
-		g.audioPlayer.Play()
-	}
+	g.openScreen(g.loading.ScreenName)
 }
 
 func (g *Game) drawScene(dst *ebiten.Image) {
@@ -503,11 +575,13 @@ func (g *Game) drawScene(dst *ebiten.Image) {
 	g.mapLevel.Draw(g.gameCanvas, mapX, mapY, 0, 0, gameWidth, gameHeight)
 	frame := g.calculateFrame()
 	g.drawDude(g.gameCanvas, dudeX, dudeY-bounce, frame)
+	g.sineSprites.Pulse = g.clock.Pulse
 	g.sineSprites.Draw(g.gameCanvas, g.carebearTime)
 
 	var op ebiten.DrawImageOptions
 	op.GeoM.Translate(gameOffsetX, gameOffsetY)
 	dst.DrawImage(g.gameCanvas, &op)
+	g.drawVisualiser(dst)
 
 	if g.loading.Active {
 		g.drawLoading(dst)
@@ -726,9 +800,30 @@ func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
 }
 `
 
+// commands are the tools that run instead of the menu when named as the
+// first argument.
+var commands = map[string]func(args []string) error{
+	"inspect": inspectCommand,
+	"render":  renderCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	audioConfig, err := parseAudioFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	rand.Seed(time.Now().UnixNano())
-	game := NewGame()
+	game := NewGame(audioConfig)
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("Cuddly Demos - Menu")
 	if err := ebiten.RunGame(game); err != nil {
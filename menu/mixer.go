@@ -0,0 +1,258 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gainRamp moves a volume towards a target by a fixed step per sample, so
+// fades are exact regardless of how reads are chunked.
+type gainRamp struct {
+	value  float64
+	target float64
+	step   float64
+}
+
+func (r *gainRamp) set(v float64) {
+	r.value, r.target, r.step = v, v, 0
+}
+
+func (r *gainRamp) fadeTo(target float64, samples int) {
+	r.target = target
+	if samples <= 0 {
+		r.value, r.step = target, 0
+		return
+	}
+	r.step = (target - r.value) / float64(samples)
+}
+
+// next returns the gain for the current sample and advances the ramp.
+func (r *gainRamp) next() float64 {
+	v := r.value
+	if r.value != r.target {
+		r.value += r.step
+		if (r.step > 0 && r.value > r.target) || (r.step < 0 && r.value < r.target) || r.step == 0 {
+			r.value = r.target
+		}
+	}
+	return v
+}
+
+func (r *gainRamp) silent() bool {
+	return r.value == 0 && r.target == 0
+}
+
+// steady reports whether next would keep returning the current value.
+func (r *gainRamp) steady() bool {
+	return r.value == r.target
+}
+
+// gainFixed converts a gain to 16.16 fixed point for applyGain.
+func gainFixed(gain float64) int64 {
+	return int64(gain * (1 << 16))
+}
+
+// applyGain scales a sample by a fixed-point gain, rounding towards zero
+// like a float conversion would.
+func applyGain(sample int16, gain int64) int16 {
+	v := int64(sample) * gain
+	if v < 0 {
+		return int16(-(-v >> 16))
+	}
+	return int16(v >> 16)
+}
+
+func durationToSamples(d time.Duration, sampleRate int) int {
+	return int(d.Seconds() * float64(sampleRate))
+}
+
+// Mixer is the single audio stream played by the game. It sums any number
+// of 16-bit stereo sources, each with its own gain ramp, so tunes can fade
+// and crossfade at sample accuracy. A channel faded to silence is not read
+// at all, which leaves its source paused until it is faded back in.
+//
+// Sources are rendered without holding the mutex, so adding, fading or
+// stopping a channel from the game never waits for a whole read. The
+// rendered count is published without the mutex at all, behind a sequence
+// number that is odd while a read is running.
+type Mixer struct {
+	mutex      sync.Mutex
+	sampleRate int
+	channels   []*MixerChannel
+	rendered   atomic.Int64
+	seq        atomic.Uint64
+
+	// Only used by Read.
+	active  []*MixerChannel
+	scratch []byte
+	mix     []int32
+}
+
+type MixerChannel struct {
+	mixer   *Mixer
+	source  io.Reader
+	gain    gainRamp
+	fades   int
+	remove  bool
+	removed bool
+
+	// Read renders with a copy of the gain, and only writes it back when
+	// the channel wasn't faded in the meantime.
+	ramp   gainRamp
+	before int
+	failed bool
+}
+
+func NewMixer(sampleRate int) *Mixer {
+	return &Mixer{sampleRate: sampleRate}
+}
+
+// Add starts mixing source at the given volume.
+func (m *Mixer) Add(source io.Reader, volume float64) *MixerChannel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	c := &MixerChannel{mixer: m, source: source}
+	c.gain.set(volume)
+	m.channels = append(m.channels, c)
+	return c
+}
+
+// FadeTo ramps the channel's volume to target over d. Fading a channel that
+// is being stopped back up keeps it.
+func (c *MixerChannel) FadeTo(target float64, d time.Duration) {
+	c.mixer.mutex.Lock()
+	defer c.mixer.mutex.Unlock()
+	c.gain.fadeTo(target, durationToSamples(d, c.mixer.sampleRate))
+	c.fades++
+	if target > 0 {
+		c.remove = false
+	}
+}
+
+// Stop fades the channel out over d, then removes it and closes its source.
+func (c *MixerChannel) Stop(d time.Duration) {
+	c.mixer.mutex.Lock()
+	defer c.mixer.mutex.Unlock()
+	c.gain.fadeTo(0, durationToSamples(d, c.mixer.sampleRate))
+	c.fades++
+	c.remove = true
+}
+
+// Removed reports whether the mixer has dropped the channel, either after
+// Stop or because its source ended.
+func (c *MixerChannel) Removed() bool {
+	c.mixer.mutex.Lock()
+	defer c.mixer.mutex.Unlock()
+	return c.removed
+}
+
+func (m *Mixer) Read(p []byte) (int, error) {
+	samples := len(p) / bytesPerSample
+	n := samples * bytesPerSample
+	if cap(m.mix) < samples*2 {
+		m.mix = make([]int32, samples*2)
+		m.scratch = make([]byte, n)
+	}
+	mix := m.mix[:samples*2]
+	clear(mix)
+
+	m.seq.Add(1)
+	defer m.seq.Add(1)
+
+	m.mutex.Lock()
+	m.active = append(m.active[:0], m.channels...)
+	for _, c := range m.active {
+		c.ramp, c.before, c.failed = c.gain, c.fades, false
+	}
+	m.mutex.Unlock()
+
+	for _, c := range m.active {
+		if c.ramp.silent() {
+			continue
+		}
+		buf := m.scratch[:n]
+		read, err := io.ReadFull(c.source, buf)
+		clear(buf[read:])
+		steady := c.ramp.steady()
+		gain := gainFixed(c.ramp.value)
+		for i := 0; i < samples; i++ {
+			if !steady {
+				gain = gainFixed(c.ramp.next())
+			}
+			l := int16(buf[i*4]) | int16(buf[i*4+1])<<8
+			r := int16(buf[i*4+2]) | int16(buf[i*4+3])<<8
+			mix[i*2] += int32(applyGain(l, gain))
+			mix[i*2+1] += int32(applyGain(r, gain))
+		}
+		c.failed = err != nil
+	}
+
+	for i, v := range mix {
+		v = min(max(v, -32768), 32767)
+		p[i*2] = byte(v)
+		p[i*2+1] = byte(v >> 8)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, c := range m.active {
+		if c.fades == c.before {
+			c.gain = c.ramp
+		}
+		if c.failed || (c.remove && c.gain.silent()) {
+			c.close()
+		}
+	}
+	channels := m.channels[:0]
+	for _, c := range m.channels {
+		if !c.removed {
+			channels = append(channels, c)
+		}
+	}
+	clear(m.channels[len(channels):])
+	m.channels = channels
+	clear(m.active)
+	m.rendered.Add(int64(samples))
+	return n, nil
+}
+
+// Position returns how much audio the mixer has rendered since it started
+// or was last rewound. Comparing it with the audio.Player's position gives
+// the latency between rendering a sample and hearing it.
+func (m *Mixer) Position() time.Duration {
+	return samplesToDuration(m.rendered.Load(), m.sampleRate)
+}
+
+// Snapshot calls fn with the rendered count and reports whether no read
+// ran while fn did, so positions read in fn match the count. It never
+// waits for the audio thread: when a read is running, fn isn't called and
+// Snapshot returns false.
+func (m *Mixer) Snapshot(fn func(rendered time.Duration)) bool {
+	seq := m.seq.Load()
+	if seq%2 != 0 {
+		return false
+	}
+	fn(samplesToDuration(m.rendered.Load(), m.sampleRate))
+	return m.seq.Load() == seq
+}
+
+// Seek only exists so the mixer can be handed to an audio.Player, which
+// seeks to flush its buffer. The mixer is a live stream and its sources
+// keep their positions; only the rendered count follows the player.
+func (m *Mixer) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		m.seq.Add(1)
+		m.rendered.Store(offset / bytesPerSample)
+		m.seq.Add(1)
+	}
+	return m.rendered.Load() * bytesPerSample, nil
+}
+
+func (c *MixerChannel) close() {
+	c.removed = true
+	if closer, ok := c.source.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func BenchmarkMixerRead(b *testing.B) {
+	benchmarkRead(b, func(data []byte) io.Reader {
+		mixer := NewMixer(defaultSampleRate)
+		mixer.Add(openPlayer(b, data, ABCPanning), 1)
+		return mixer
+	})
+}
+
+// readerFunc lets a test run code inside a mixer read.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestMixerSnapshot(t *testing.T) {
+	mixer := NewMixer(defaultSampleRate)
+	var during bool
+	mixer.Add(readerFunc(func(p []byte) (int, error) {
+		during = mixer.Snapshot(func(time.Duration) {
+			t.Error("snapshot taken while the mixer was reading")
+		})
+		return len(p), nil
+	}), 1)
+
+	buf := make([]byte, 100*bytesPerSample)
+	mixer.Read(buf)
+	if during {
+		t.Error("Snapshot reported a clean snapshot during a read")
+	}
+	var rendered time.Duration
+	if !mixer.Snapshot(func(r time.Duration) { rendered = r }) {
+		t.Fatal("Snapshot failed between reads")
+	}
+	if want := samplesToDuration(100, defaultSampleRate); rendered != want {
+		t.Errorf("rendered %s, want %s", rendered, want)
+	}
+}
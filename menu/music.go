@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// musicFade is how long a crossfade between tunes takes.
+	musicFade = time.Second
+	// loaderFade is how quickly the music dips while a screen loads.
+	loaderFade = 300 * time.Millisecond
+)
+
+// MusicZone is a rectangle of the map, in tiles, with its own tune. The
+// path is relative to the working directory like the other assets.
+type MusicZone struct {
+	X     int
+	Y     int
+	W     int
+	H     int
+	Music string
+}
+
+type areaTune struct {
+	path    string
+	source  MusicSource
+	channel *MixerChannel
+}
+
+// AreaMusic crossfades between the menu music and the tunes belonging to
+// doors, screens and map zones. Path is the tune that should be heard, or
+// empty for the menu music, and Silent mutes everything.
+type AreaMusic struct {
+	Path   string
+	Silent bool
+
+	tunes  []*areaTune
+	menu   float64
+	failed map[string]bool
+}
+
+// doorMusic returns the tune declared by the door leading to a screen.
+func doorMusic(name string) string {
+	for _, d := range demoScreens {
+		if d.Name == name && d.Music != "" {
+			return screenAssetPath(name, d.Music)
+		}
+	}
+	return ""
+}
+
+func zoneMusic(x, y int) string {
+	for _, z := range musicZones {
+		if x >= z.X && x < z.X+z.W && y >= z.Y && y < z.Y+z.H {
+			return z.Music
+		}
+	}
+	return ""
+}
+
+// wantedMusic picks the tune for what is on screen: the open screen's own
+// tune, the tune of the door being loaded, or that of the zone the dude is
+// standing in. Doors without a tune load in silence.
+func (g *Game) wantedMusic() (path string, silent bool) {
+	if g.screen.Active != nil {
+		return g.screen.Music, false
+	}
+	if g.loading.Active {
+		return g.loading.Music, g.loading.Music == ""
+	}
+	return zoneMusic(int(g.model.Position.X)/tileSize, int(g.model.Position.Y)/tileSize), false
+}
+
+func (g *Game) updateAreaMusic() {
+	if g.mixer == nil {
+		return
+	}
+	m := &g.areaMusic
+	path, silent := g.wantedMusic()
+	if path == m.Path && silent == m.Silent {
+		return
+	}
+	fade := musicFade
+	if silent || m.Silent {
+		fade = loaderFade
+	}
+	m.Path, m.Silent = path, silent
+
+	if path != "" && m.find(path) == nil && !m.failed[path] {
+		if tune, err := g.openAreaTune(path); err != nil {
+			log.Printf("failed to play %s (%v): keeping current music", path, err)
+			if m.failed == nil {
+				m.failed = make(map[string]bool)
+			}
+			m.failed[path] = true
+		} else {
+			m.tunes = append(m.tunes, tune)
+			g.nowPlaying.Show(tune.source.Info())
+		}
+	}
+
+	// Channels left silent for long enough have been dropped by the mixer.
+	tunes := m.tunes[:0]
+	for _, t := range m.tunes {
+		if !t.channel.Removed() {
+			tunes = append(tunes, t)
+		}
+	}
+	m.tunes = tunes
+
+	playing := m.find(path)
+	for _, t := range m.tunes {
+		if t == playing && !silent {
+			t.channel.FadeTo(1, fade)
+		} else {
+			t.channel.Stop(fade)
+		}
+	}
+	menu := 1.0
+	if playing != nil || silent {
+		menu = 0
+	}
+	if menu != m.menu {
+		m.menu = menu
+		g.menuChannel.FadeTo(menu, fade)
+	}
+}
+
+func (g *Game) openAreaTune(path string) (*areaTune, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	source, err := OpenMusic(path, data, g.audioConfig.SampleRate, true)
+	if err != nil {
+		return nil, err
+	}
+	g.applyOutputTo(source)
+	return &areaTune{path: path, source: source, channel: g.mixer.Add(source, 0)}, nil
+}
+
+func (m *AreaMusic) find(path string) *areaTune {
+	for _, t := range m.tunes {
+		if t.path == path && !t.channel.Removed() {
+			return t
+		}
+	}
+	return nil
+}
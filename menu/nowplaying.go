@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	nowPlayingDuration = 60 * 5
+	nowPlayingFade     = 30
+	nowPlayingMargin   = 12
+)
+
+// NowPlaying is the overlay crediting the current tune. It pops up for a
+// few seconds whenever a track starts and can be pinned with a key.
+type NowPlaying struct {
+	Info   SourceInfo
+	Timer  int
+	Pinned bool
+
+	image *ebiten.Image
+}
+
+func (n *NowPlaying) Show(info SourceInfo) {
+	n.Info = info
+	n.Timer = nowPlayingDuration
+	if n.image != nil {
+		n.image.Deallocate()
+		n.image = nil
+	}
+}
+
+func (n *NowPlaying) Toggle() {
+	n.Pinned = !n.Pinned
+	n.Timer = 0
+}
+
+func (n *NowPlaying) Update() {
+	if n.Timer > 0 {
+		n.Timer--
+	}
+}
+
+func (n *NowPlaying) Draw(dst *ebiten.Image) {
+	if n.Info == (SourceInfo{}) {
+		return
+	}
+	alpha := 1.0
+	if !n.Pinned {
+		if n.Timer <= 0 {
+			return
+		}
+		if n.Timer < nowPlayingFade {
+			alpha = float64(n.Timer) / nowPlayingFade
+		}
+	}
+
+	if n.image == nil {
+		n.image = renderNowPlaying(n.Info)
+	}
+	var op ebiten.DrawImageOptions
+	op.GeoM.Translate(float64(dst.Bounds().Dx()-n.image.Bounds().Dx()-nowPlayingMargin), nowPlayingMargin)
+	op.ColorScale.ScaleAlpha(float32(alpha))
+	dst.DrawImage(n.image, &op)
+}
+
+func renderNowPlaying(info SourceInfo) *ebiten.Image {
+	lines := []string{"NOW PLAYING", info.Title}
+	if info.Author != "" {
+		lines = append(lines, "BY "+info.Author)
+	}
+	if info.Comment != "" {
+		lines = append(lines, info.Comment)
+	}
+	lines = append(lines, fmt.Sprintf("%s  %s", info.Format, formatDuration(info.Duration)))
+
+	width := 0
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	img := ebiten.NewImage(width*placeholderGlyphW+16, len(lines)*placeholderGlyphH+8)
+	img.Fill(color.RGBA{0, 0, 40, 200})
+	ebitenutil.DebugPrintAt(img, strings.Join(lines, "\n"), 8, 4)
+	return img
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
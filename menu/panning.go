@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Panning places each AY voice between the left (0) and right (1) side.
+// Tunes are mono on the real machine, so MonoPanning is the default.
+type Panning [ayVoices]float64
+
+var (
+	MonoPanning = Panning{0.5, 0.5, 0.5}
+	// ABCPanning and ACBPanning are the usual AY player layouts, with the
+	// outer voices kept a little off the edges so headphones aren't harsh.
+	ABCPanning = Panning{0.1, 0.5, 0.9}
+	ACBPanning = Panning{0.1, 0.9, 0.5}
+)
+
+// panPresets are the layouts the menu cycles through.
+var panPresets = []Panning{MonoPanning, ABCPanning, ACBPanning}
+
+func (p Panning) Mono() bool {
+	return p == MonoPanning
+}
+
+func (p Panning) String() string {
+	switch p {
+	case MonoPanning:
+		return "MONO"
+	case ABCPanning:
+		return "ABC"
+	case ACBPanning:
+		return "ACB"
+	}
+	return fmt.Sprintf("%g,%g,%g", p[0], p[1], p[2])
+}
+
+// gains turns the pan positions into left and right gains with a constant
+// power law, scaled so a centred voice is as loud as in mono.
+func (p Panning) gains() [ayVoices][2]float64 {
+	var g [ayVoices][2]float64
+	for i, pan := range p {
+		angle := min(max(pan, 0), 1) * math.Pi / 2
+		g[i] = [2]float64{math.Sqrt2 * math.Cos(angle), math.Sqrt2 * math.Sin(angle)}
+	}
+	return g
+}
+
+// ParsePanning reads "mono", "abc", "acb" or three comma-separated pan
+// positions from 0 (left) to 1 (right) for voices A, B and C.
+func ParsePanning(s string) (Panning, error) {
+	switch strings.ToLower(s) {
+	case "mono":
+		return MonoPanning, nil
+	case "abc":
+		return ABCPanning, nil
+	case "acb":
+		return ACBPanning, nil
+	}
+	fields := strings.Split(s, ",")
+	if len(fields) != ayVoices {
+		return Panning{}, fmt.Errorf("panning %q: want mono, abc, acb or three positions", s)
+	}
+	var p Panning
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || v < 0 || v > 1 {
+			return Panning{}, fmt.Errorf("panning %q: positions must be between 0 and 1", s)
+		}
+		p[i] = v
+	}
+	return p, nil
+}
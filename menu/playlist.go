@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	musicDir         = "assets/music"
+	playlistManifest = "playlist.m3u"
+)
+
+// Track is one entry in a playlist. Data is used instead of reading Path
+// when the tune is already in memory.
+type Track struct {
+	Path string
+	Data []byte
+}
+
+func (t Track) load() ([]byte, error) {
+	if t.Data != nil {
+		return t.Data, nil
+	}
+	return os.ReadFile(t.Path)
+}
+
+type RepeatMode int
+
+const (
+	RepeatAll RepeatMode = iota
+	RepeatOne
+	RepeatOff
+)
+
+func (r RepeatMode) String() string {
+	switch r {
+	case RepeatOne:
+		return "ONE"
+	case RepeatOff:
+		return "OFF"
+	default:
+		return "ALL"
+	}
+}
+
+// Playlist keeps the play order of a list of tracks. Tracks that fail to
+// open are marked and skipped from then on.
+type Playlist struct {
+	Tracks  []Track
+	Shuffle bool
+	Repeat  RepeatMode
+
+	order  []int
+	pos    int
+	failed []bool
+}
+
+func NewPlaylist(tracks []Track) *Playlist {
+	p := &Playlist{Tracks: tracks, failed: make([]bool, len(tracks))}
+	p.order = make([]int, len(tracks))
+	for i := range p.order {
+		p.order[i] = i
+	}
+	return p
+}
+
+// LoadPlaylist reads the tracks from a manifest, or from every music file
+// in a directory when dir has no manifest. Manifest lines are paths relative to
+// the manifest; blank lines and lines starting with # are ignored.
+func LoadPlaylist(dir string) ([]Track, error) {
+	manifest := filepath.Join(dir, playlistManifest)
+	data, err := os.ReadFile(manifest)
+	if err == nil {
+		var tracks []Track
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dir, line)
+			}
+			tracks = append(tracks, Track{Path: line})
+		}
+		return tracks, scanner.Err()
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && isMusicFile(e.Name()) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no music files in %s", dir)
+	}
+	sort.Strings(paths)
+	tracks := make([]Track, len(paths))
+	for i, path := range paths {
+		tracks[i] = Track{Path: path}
+	}
+	return tracks, nil
+}
+
+func (p *Playlist) Index() int {
+	return p.order[p.pos]
+}
+
+func (p *Playlist) Current() Track {
+	return p.Tracks[p.Index()]
+}
+
+// peek returns the position step tracks away from the current one, going
+// further the same way past tracks that failed, or false when that runs off
+// either end and the playlist doesn't repeat or nothing is left to play.
+// Repeating a single track only applies to tracks ending on their own, so
+// skipping still moves through the list.
+func (p *Playlist) peek(step int) (int, bool) {
+	dir := 1
+	if step < 0 {
+		dir = -1
+	}
+	pos := p.pos + step
+	for range p.order {
+		if pos < 0 || pos >= len(p.order) {
+			if p.Repeat == RepeatOff {
+				return 0, false
+			}
+			pos = (pos%len(p.order) + len(p.order)) % len(p.order)
+		}
+		if !p.failed[p.order[pos]] {
+			return pos, true
+		}
+		pos += dir
+	}
+	return 0, false
+}
+
+// fail marks the track at a position in play order as unplayable.
+func (p *Playlist) fail(pos int, err error) {
+	index := p.order[pos]
+	if !p.failed[index] {
+		log.Printf("failed to play %s (%v): skipping it", p.Tracks[index].Path, err)
+		p.failed[index] = true
+	}
+}
+
+func (p *Playlist) SetShuffle(on bool) {
+	p.Shuffle = on
+	current := p.Index()
+	for i := range p.order {
+		p.order[i] = i
+	}
+	if on {
+		rand.Shuffle(len(p.order), func(i, j int) {
+			p.order[i], p.order[j] = p.order[j], p.order[i]
+		})
+	}
+	// Keep playing the same track from its new place in the order.
+	for i, index := range p.order {
+		if index == current {
+			p.pos = i
+		}
+	}
+}
+
+// PlaylistStream is the audio source for a playlist. It renders the current
+// track and, when a track ends, carries on with the next one inside the same
+// read so there is no gap between tunes. The next track is loaded ahead of
+// time by Preload, outside the audio thread. Reads never fail: when nothing
+// is left to play the stream is silent, so the mixer keeps its channel and
+// the music comes back once there is something to play again.
+type PlaylistStream struct {
+	mutex      sync.Mutex
+	playlist   *Playlist
+	sampleRate int
+	current    MusicSource
+	next       MusicSource
+	nextPos    int
+	generation int
+	epoch      int
+}
+
+var errNoPlayableTrack = errors.New("no playable track")
+
+func NewPlaylistStream(playlist *Playlist, sampleRate int) (*PlaylistStream, error) {
+	s := &PlaylistStream{playlist: playlist, sampleRate: sampleRate}
+	current, pos, err := s.openFrom(0)
+	if err != nil {
+		return nil, err
+	}
+	s.current = current
+	playlist.pos = pos
+	return s, nil
+}
+
+func (s *PlaylistStream) open(track Track) (MusicSource, error) {
+	data, err := track.load()
+	if err != nil {
+		return nil, err
+	}
+	return OpenMusic(track.Path, data, s.sampleRate, s.playlist.Repeat == RepeatOne)
+}
+
+// openFrom opens the first track that plays, starting step tracks away from
+// the current one. Tracks that fail on the way are marked.
+func (s *PlaylistStream) openFrom(step int) (MusicSource, int, error) {
+	for {
+		pos, ok := s.playlist.peek(step)
+		if !ok {
+			return nil, 0, errNoPlayableTrack
+		}
+		source, err := s.open(s.playlist.Tracks[s.playlist.order[pos]])
+		if err == nil {
+			return source, pos, nil
+		}
+		s.playlist.fail(pos, err)
+	}
+}
+
+func (s *PlaylistStream) Read(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	filled := 0
+	for filled < len(p) {
+		n, err := s.current.Read(p[filled:])
+		filled += n
+		if err == nil {
+			return filled, nil
+		}
+		if err != io.EOF {
+			s.playlist.fail(s.playlist.pos, err)
+		}
+		if s.next == nil {
+			// Nothing preloaded: either the playlist has ended or the
+			// loader fell behind, in which case the track is opened here.
+			next, pos, err := s.openFrom(1)
+			if err != nil {
+				clear(p[filled:])
+				return len(p), nil
+			}
+			s.next, s.nextPos = next, pos
+		}
+		s.current.Close()
+		s.current, s.next = s.next, nil
+		s.playlist.pos = s.nextPos
+		s.generation++
+		s.epoch++
+	}
+	return filled, nil
+}
+
+func (s *PlaylistStream) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.current.Seek(offset, whence)
+}
+
+// Current returns the playing track's source and a counter that changes
+// every time a different track starts.
+func (s *PlaylistStream) Current() (MusicSource, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.current, s.generation
+}
+
+func (s *PlaylistStream) Playlist() *Playlist {
+	return s.playlist
+}
+
+// Track returns the number of the playing track in play order and the
+// length of the playlist.
+func (s *PlaylistStream) Track() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.playlist.pos + 1, len(s.playlist.Tracks)
+}
+
+// Preload opens the track that follows the current one if it isn't ready
+// yet. Decoding happens without holding the lock so reads aren't held up.
+// A track that fails is marked, so the next call tries the one after it.
+// Nothing is preloaded while the current track repeats.
+func (s *PlaylistStream) Preload() {
+	s.mutex.Lock()
+	pos, ok := s.playlist.peek(1)
+	if s.next != nil || !ok || s.playlist.Repeat == RepeatOne {
+		s.mutex.Unlock()
+		return
+	}
+	track := s.playlist.Tracks[s.playlist.order[pos]]
+	epoch := s.epoch
+	s.mutex.Unlock()
+
+	next, err := s.open(track)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.epoch != epoch {
+		if next != nil {
+			next.Close()
+		}
+		return
+	}
+	if err != nil {
+		s.playlist.fail(pos, err)
+		return
+	}
+	if s.next != nil {
+		next.Close()
+		return
+	}
+	s.next, s.nextPos = next, pos
+}
+
+// Skip jumps step tracks forwards or backwards.
+func (s *PlaylistStream) Skip(step int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.playlist.peek(step); !ok {
+		return nil
+	}
+	player, pos, err := s.openFrom(step)
+	if err != nil {
+		return err
+	}
+	s.current.Close()
+	s.dropNext()
+	s.current = player
+	s.playlist.pos = pos
+	s.generation++
+	return nil
+}
+
+func (s *PlaylistStream) SetShuffle(on bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.playlist.SetShuffle(on)
+	s.dropNext()
+}
+
+func (s *PlaylistStream) SetRepeat(mode RepeatMode) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.playlist.Repeat = mode
+	s.current.SetLoop(mode == RepeatOne)
+	s.dropNext()
+}
+
+// dropNext discards the preloaded track after the current track, order or
+// repeat mode has changed, so Preload picks the right one. A load already in
+// progress is thrown away when it finishes.
+func (s *PlaylistStream) dropNext() {
+	if s.next != nil {
+		s.next.Close()
+		s.next = nil
+	}
+	s.epoch++
+}
+
+func (s *PlaylistStream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.current.Close()
+	s.dropNext()
+}
+
+// updateMusic handles the playlist keys and follows track changes made by
+// the audio thread.
+func (g *Game) updateMusic() {
+	if g.music == nil {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyJ) {
+		g.setJukebox(!g.jukebox)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+		g.skipTrack(-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+		g.skipTrack(1)
+	}
+	if g.jukebox && inpututil.IsKeyJustPressed(ebiten.KeyF7) {
+		g.music.SetShuffle(!g.music.Playlist().Shuffle)
+		g.setScrollText(g.jukeboxText())
+	}
+	if g.jukebox && inpututil.IsKeyJustPressed(ebiten.KeyF8) {
+		g.music.SetRepeat((g.music.Playlist().Repeat + 1) % (RepeatOff + 1))
+		g.setScrollText(g.jukeboxText())
+	}
+
+	g.music.Preload()
+	if source, generation := g.music.Current(); generation != g.musicGeneration {
+		g.musicGeneration = generation
+		g.setMenuSource(source)
+		g.nowPlaying.Show(source.Info())
+		if g.jukebox {
+			g.setScrollText(g.jukeboxText())
+		}
+	}
+}
+
+// setMenuSource follows a new menu track. The voice controls only apply
+// to tunes played on the AY emulation.
+func (g *Game) setMenuSource(source MusicSource) {
+	g.menuSource = source
+	g.ymPlayer, _ = source.(*YMPlayer)
+	if g.ymPlayer != nil {
+		g.ymPlayer.SetVoices(g.voices.Audible())
+	}
+	g.applyOutputTo(source)
+}
+
+func (g *Game) skipTrack(step int) {
+	if err := g.music.Skip(step); err != nil {
+		log.Printf("failed to change track: %v", err)
+	}
+}
+
+// setJukebox switches between looping the current tune and playing through
+// the playlist with the scroller showing what is on.
+func (g *Game) setJukebox(on bool) {
+	g.jukebox = on
+	if on {
+		g.music.SetRepeat(RepeatAll)
+		g.setScrollText(g.jukeboxText())
+		return
+	}
+	g.music.SetRepeat(RepeatOne)
+	g.setScrollText(scrollTextData)
+}
+
+func (g *Game) setScrollText(text string) {
+	scrollMap := BuildScrollMap(text)
+	g.scrollerLevel = NewTileMap([][]int{scrollMap}, g.scrollTiles)
+	g.scrollerLength = len(scrollMap) * scrollTileW
+	g.model.ScrollerPosition = 0
+}
+
+func (g *Game) jukeboxText() string {
+	playlist := g.music.Playlist()
+	track, tracks := g.music.Track()
+	source, _ := g.music.Current()
+	info := source.Info()
+	title := info.Title
+	if info.Author != "" {
+		title += " BY " + info.Author
+	}
+	shuffle := "OFF"
+	if playlist.Shuffle {
+		shuffle = "ON"
+	}
+	text := fmt.Sprintf("          JUKEBOX    NOW PLAYING: %s (%s)    TRACK %d OF %d    SHUFFLE %s    REPEAT %s    "+
+		"F5 PREVIOUS  F6 NEXT  F7 SHUFFLE  F8 REPEAT  J EXIT          ",
+		title, formatDuration(info.Duration), track, tracks, shuffle, playlist.Repeat)
+	// The chrome font only has upper case.
+	return strings.ToUpper(text)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// The point cloud maths is kept free of ebiten so it can be exercised
+// without a graphics context.
+
+type Vec3 struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+func (v Vec3) Lerp(o Vec3, t float64) Vec3 {
+	return Vec3{
+		X: v.X + (o.X-v.X)*t,
+		Y: v.Y + (o.Y-v.Y)*t,
+		Z: v.Z + (o.Z-v.Z)*t,
+	}
+}
+
+// Rotation holds Euler angles in radians, applied X, then Y, then Z.
+type Rotation struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+func (r Rotation) Apply(v Vec3) Vec3 {
+	sx, cx := math.Sincos(r.X)
+	sy, cy := math.Sincos(r.Y)
+	sz, cz := math.Sincos(r.Z)
+
+	y := v.Y*cx - v.Z*sx
+	z := v.Y*sx + v.Z*cx
+	v.Y, v.Z = y, z
+
+	x := v.X*cy + v.Z*sy
+	z = -v.X*sy + v.Z*cy
+	v.X, v.Z = x, z
+
+	x = v.X*cz - v.Y*sz
+	y = v.X*sz + v.Y*cz
+	v.X, v.Y = x, y
+	return v
+}
+
+// Camera projects points onto the screen with a simple pinhole model. The
+// camera looks down +Z from Distance units in front of the origin.
+type Camera struct {
+	Distance float64
+	Focal    float64
+	CenterX  float64
+	CenterY  float64
+}
+
+type ProjectedPoint struct {
+	X     float64
+	Y     float64
+	Depth float64
+	Scale float64
+}
+
+func (c Camera) Project(v Vec3) (ProjectedPoint, bool) {
+	z := v.Z + c.Distance
+	if z <= 0.01 {
+		return ProjectedPoint{}, false
+	}
+	scale := c.Focal / z
+	return ProjectedPoint{
+		X:     c.CenterX + v.X*scale,
+		Y:     c.CenterY + v.Y*scale,
+		Depth: z,
+		Scale: scale,
+	}, true
+}
+
+// TransformPoints rotates and projects src into dst, reusing dst's storage,
+// and returns the points sorted back to front.
+func TransformPoints(dst []ProjectedPoint, src []Vec3, rot Rotation, cam Camera) []ProjectedPoint {
+	dst = dst[:0]
+	for _, v := range src {
+		if p, ok := cam.Project(rot.Apply(v)); ok {
+			dst = append(dst, p)
+		}
+	}
+	sortByDepth(dst)
+	return dst
+}
+
+func sortByDepth(points []ProjectedPoint) {
+	// Insertion sort: the order barely changes between frames, so this is
+	// close to linear in practice.
+	for i := 1; i < len(points); i++ {
+		p := points[i]
+		j := i - 1
+		for j >= 0 && points[j].Depth < p.Depth {
+			points[j+1] = points[j]
+			j--
+		}
+		points[j+1] = p
+	}
+}
+
+// MorphPoints blends a into b with an eased factor t in [0, 1]. Both shapes
+// must hold the same number of points.
+func MorphPoints(dst, a, b []Vec3, t float64) []Vec3 {
+	t = easeInOut(t)
+	dst = dst[:0]
+	for i := range a {
+		dst = append(dst, a[i].Lerp(b[i], t))
+	}
+	return dst
+}
+
+func easeInOut(t float64) float64 {
+	if t <= 0 {
+		return 0
+	}
+	if t >= 1 {
+		return 1
+	}
+	return t * t * (3 - 2*t)
+}
+
+// SpherePoints spreads n points evenly over a unit sphere.
+func SpherePoints(n int) []Vec3 {
+	points := make([]Vec3, n)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	for i := range points {
+		y := 1 - (float64(i)+0.5)/float64(n)*2
+		r := math.Sqrt(1 - y*y)
+		s, c := math.Sincos(golden * float64(i))
+		points[i] = Vec3{X: c * r, Y: y, Z: s * r}
+	}
+	return points
+}
+
+// CubePoints places n points on the edges and faces of a cube spanning
+// [-0.8, 0.8] on every axis.
+func CubePoints(n int) []Vec3 {
+	const half = 0.8
+	side := int(math.Ceil(math.Sqrt(float64(n) / 6)))
+	if side < 2 {
+		side = 2
+	}
+	grid := make([]Vec3, 0, side*side*6)
+	for face := 0; face < 6; face++ {
+		for i := 0; i < side; i++ {
+			for j := 0; j < side; j++ {
+				u := (float64(i)/float64(side-1)*2 - 1) * half
+				v := (float64(j)/float64(side-1)*2 - 1) * half
+				var p Vec3
+				switch face {
+				case 0:
+					p = Vec3{X: u, Y: v, Z: half}
+				case 1:
+					p = Vec3{X: u, Y: v, Z: -half}
+				case 2:
+					p = Vec3{X: u, Y: half, Z: v}
+				case 3:
+					p = Vec3{X: u, Y: -half, Z: v}
+				case 4:
+					p = Vec3{X: half, Y: u, Z: v}
+				default:
+					p = Vec3{X: -half, Y: u, Z: v}
+				}
+				grid = append(grid, p)
+			}
+		}
+	}
+	return ResamplePoints(grid, n)
+}
+
+// TorusPoints places n points on a torus lying in the XZ plane.
+func TorusPoints(n int, major, minor float64) []Vec3 {
+	rings := int(math.Sqrt(float64(n) * major / minor))
+	if rings < 3 {
+		rings = 3
+	}
+	perRing := (n + rings - 1) / rings
+	points := make([]Vec3, 0, rings*perRing)
+	for i := 0; i < rings; i++ {
+		su, cu := math.Sincos(float64(i) / float64(rings) * 2 * math.Pi)
+		for j := 0; j < perRing; j++ {
+			sv, cv := math.Sincos(float64(j) / float64(perRing) * 2 * math.Pi)
+			r := major + minor*cv
+			points = append(points, Vec3{X: r * cu, Y: minor * sv, Z: r * su})
+		}
+	}
+	return ResamplePoints(points, n)
+}
+
+// MaskPoints turns the opaque pixels of mask into a flat point cloud
+// scaled to fit the unit square, with n points in total.
+func MaskPoints(mask image.Image, n int) []Vec3 {
+	b := mask.Bounds()
+	size := math.Max(float64(b.Dx()), float64(b.Dy()))
+	if size == 0 {
+		return SpherePoints(n)
+	}
+	var points []Vec3
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := mask.At(x, y).RGBA(); a < 0x8000 {
+				continue
+			}
+			points = append(points, Vec3{
+				X: (float64(x-b.Min.X) - float64(b.Dx())/2 + 0.5) / size * 2,
+				Y: (float64(y-b.Min.Y) - float64(b.Dy())/2 + 0.5) / size * 2,
+			})
+		}
+	}
+	if len(points) == 0 {
+		return SpherePoints(n)
+	}
+	return ResamplePoints(points, n)
+}
+
+// ResamplePoints returns exactly n points picked evenly from src, repeating
+// points when src is too small. Shapes need equal sizes to morph.
+func ResamplePoints(src []Vec3, n int) []Vec3 {
+	out := make([]Vec3, n)
+	if len(src) == 0 {
+		return out
+	}
+	for i := range out {
+		out[i] = src[i*len(src)/n]
+	}
+	return out
+}
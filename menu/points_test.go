@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func nearVec(a, b Vec3) bool {
+	return near(a.X, b.X) && near(a.Y, b.Y) && near(a.Z, b.Z)
+}
+
+func TestRotationApply(t *testing.T) {
+	quarter := math.Pi / 2
+	for _, tc := range []struct {
+		rot      Rotation
+		in, want Vec3
+	}{
+		{Rotation{X: quarter}, Vec3{Y: 1}, Vec3{Z: 1}},
+		{Rotation{Y: quarter}, Vec3{X: 1}, Vec3{Z: -1}},
+		{Rotation{Z: quarter}, Vec3{X: 1}, Vec3{Y: 1}},
+		// X is applied first, then Y.
+		{Rotation{X: quarter, Y: quarter}, Vec3{Y: 1}, Vec3{X: 1}},
+	} {
+		if got := tc.rot.Apply(tc.in); !nearVec(got, tc.want) {
+			t.Errorf("%+v applied to %+v = %+v, want %+v", tc.rot, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCameraProject(t *testing.T) {
+	cam := Camera{Distance: 3.2, Focal: 220, CenterX: 160, CenterY: 100}
+	got, ok := cam.Project(Vec3{X: 1, Y: 0.5, Z: 0.8})
+	want := ProjectedPoint{X: 215, Y: 127.5, Depth: 4, Scale: 55}
+	if !ok || !near(got.X, want.X) || !near(got.Y, want.Y) || !near(got.Depth, want.Depth) || !near(got.Scale, want.Scale) {
+		t.Errorf("Project = %+v, %v, want %+v", got, ok, want)
+	}
+	if _, ok := cam.Project(Vec3{Z: -3.2}); ok {
+		t.Error("a point at the camera was projected")
+	}
+}
+
+// TestTransformPointsFrame checks the points SPREADPOINT draws one second
+// in, for a small sphere, sorted back to front.
+func TestTransformPointsFrame(t *testing.T) {
+	rot := Rotation{X: 0.53, Y: 0.71, Z: math.Sin(0.3) * 0.4}
+	cam := Camera{Distance: 3.2, Focal: 100}
+	want := []ProjectedPoint{
+		{X: -1.8387, Y: -2.9813, Depth: 4.1892, Scale: 23.8710},
+		{X: 21.8301, Y: 23.9136, Depth: 3.0564, Scale: 32.7184},
+		{X: 15.3132, Y: -28.8762, Depth: 2.9936, Scale: 33.4045},
+		{X: -24.3522, Y: 8.4326, Depth: 2.4179, Scale: 41.3589},
+	}
+	got := TransformPoints(nil, SpherePoints(len(want)), rot, cam)
+	if len(got) != len(want) {
+		t.Fatalf("got %d points, want %d", len(got), len(want))
+	}
+	for i, p := range got {
+		w := want[i]
+		if !near(p.X, w.X) || !near(p.Y, w.Y) || !near(p.Depth, w.Depth) || !near(p.Scale, w.Scale) {
+			t.Errorf("point %d = %+v, want %+v", i, p, w)
+		}
+	}
+}
+
+func TestShapePoints(t *testing.T) {
+	const n = 100
+	for i, p := range SpherePoints(n) {
+		if r := math.Sqrt(p.X*p.X + p.Y*p.Y + p.Z*p.Z); !near(r, 1) {
+			t.Errorf("sphere point %d is %.4f from the middle", i, r)
+		}
+	}
+	for i, p := range CubePoints(n) {
+		if m := max(math.Abs(p.X), math.Abs(p.Y), math.Abs(p.Z)); !near(m, 0.8) {
+			t.Errorf("cube point %d %+v is off the surface", i, p)
+		}
+	}
+	for _, shape := range [][]Vec3{SpherePoints(n), CubePoints(n), TorusPoints(n, 0.7, 0.3)} {
+		if len(shape) != n {
+			t.Errorf("got %d points, want %d", len(shape), n)
+		}
+	}
+}
+
+func TestMorphPoints(t *testing.T) {
+	a := []Vec3{{X: -1}, {Y: 2}}
+	b := []Vec3{{X: 1}, {Y: 4}}
+	for _, tc := range []struct {
+		t    float64
+		want []Vec3
+	}{
+		{0, a},
+		{0.5, []Vec3{{}, {Y: 3}}},
+		{1, b},
+	} {
+		got := MorphPoints(nil, a, b, tc.t)
+		for i := range got {
+			if !nearVec(got[i], tc.want[i]) {
+				t.Errorf("at %.1f point %d = %+v, want %+v", tc.t, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
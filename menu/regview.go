@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var voiceKeys = [ayVoices]ebiten.Key{ebiten.Key1, ebiten.Key2, ebiten.Key3}
+
+// VoiceControl holds the mute and solo switches for the menu tune's voices
+// and the stereo layout and output filter used for every tune. A soloed
+// voice is heard even when muted.
+type VoiceControl struct {
+	Muted   Voices
+	Solo    Voices
+	Panning Panning
+	Filter  OutputFilter
+	Window  bool
+}
+
+func (v VoiceControl) Audible() Voices {
+	if v.Solo != 0 {
+		return v.Solo
+	}
+	return allVoices &^ v.Muted
+}
+
+// updateVoices handles the register window: F9 opens it, and while it is
+// open 1-3 mute voices A-C, Shift with 1-3 solos them and P cycles the
+// stereo layout. F10 cycles the output filter at any time.
+func (g *Game) updateVoices() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+		g.voices.Window = !g.voices.Window
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+		g.voices.Filter = (g.voices.Filter + 1) % outputFilters
+		g.applyOutput()
+	}
+	if !g.voices.Window || g.ymPlayer == nil {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.voices.Panning = nextPanning(g.voices.Panning)
+		g.applyOutput()
+	}
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	for i, key := range voiceKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		if shift {
+			g.voices.Solo ^= 1 << i
+		} else {
+			g.voices.Muted ^= 1 << i
+		}
+		g.ymPlayer.SetVoices(g.voices.Audible())
+	}
+}
+
+func (g *Game) drawRegisterWindow(dst *ebiten.Image) {
+	if !g.voices.Window || g.ymPlayer == nil {
+		return
+	}
+	state := g.ymPlayer.State()
+	clock := g.ymPlayer.Clock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "YM REGISTERS  FRAME %05d\n", state.Frame)
+	b.WriteString("   PERIOD  FREQ      VOL  TONE NOISE\n")
+	for i := 0; i < ayVoices; i++ {
+		vol := fmt.Sprintf("%3d", state.Volume(i))
+		if state.UsesEnvelope(i) {
+			vol = "ENV"
+		}
+		status := ""
+		switch {
+		case g.voices.Solo.Has(i):
+			status = "SOLO"
+		case g.voices.Solo != 0 || g.voices.Muted.Has(i):
+			status = "MUTE"
+		}
+		fmt.Fprintf(&b, "%c  %04X    %7.1fHz %s  %-4s %-5s %s\n", 'A'+i, state.TonePeriod(i),
+			state.ToneFrequency(i, clock), vol, onOff(state.ToneEnabled(i)), onOff(state.NoiseEnabled(i)), status)
+	}
+	fmt.Fprintf(&b, "NOISE %02d  ENV %04X  SHAPE %X\n", state.NoisePeriod(), state.EnvelopePeriod(), state.EnvelopeShape())
+	fmt.Fprintf(&b, "STEREO %s  FILTER %s  %d HZ  UNDERRUNS %d\n", g.voices.Panning, g.voices.Filter,
+		g.audioConfig.SampleRate, g.underruns.Count)
+	b.WriteString("1-3 MUTE  SHIFT+1-3 SOLO  P STEREO  F10 FILTER  F9 CLOSE")
+
+	const x, y = 12, 12
+	text := b.String()
+	width := 0
+	for _, line := range strings.Split(text, "\n") {
+		width = max(width, len(line))
+	}
+	height := (strings.Count(text, "\n") + 1) * placeholderGlyphH
+	ebitenutil.DrawRect(dst, x, y, float64(width*placeholderGlyphW+16), float64(height+8), color.RGBA{0, 0, 0, 200})
+	ebitenutil.DebugPrintAt(dst, text, x+8, y+4)
+}
+
+// applyOutput passes the stereo layout and output filter on to every tune
+// that is open.
+func (g *Game) applyOutput() {
+	g.applyOutputTo(g.menuSource)
+	for _, t := range g.areaMusic.tunes {
+		g.applyOutputTo(t.source)
+	}
+}
+
+func (g *Game) applyOutputTo(source MusicSource) {
+	if ym, ok := source.(*YMPlayer); ok {
+		ym.SetPanning(g.voices.Panning)
+		ym.SetFilter(g.voices.Filter)
+	}
+}
+
+// nextPanning returns the preset after p, starting over from mono when p
+// is the last one or a custom layout.
+func nextPanning(p Panning) Panning {
+	for i, preset := range panPresets {
+		if preset == p {
+			return panPresets[(i+1)%len(panPresets)]
+		}
+	}
+	return panPresets[0]
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
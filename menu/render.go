@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const wavHeaderSize = 44
+
+// renderCommand renders a YM file to a 16-bit stereo WAV file through
+// YMPlayer.Read, so the output matches what the menu plays.
+//
+//	menu render [-rate N] [-loops N | -duration D] [-fade D] [-pan P] [-filter F] in.ym out.wav
+func renderCommand(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	rate := flags.Int("rate", defaultSampleRate, "output sample rate in Hz")
+	loops := flags.Int("loops", 1, "number of times to play the tune")
+	duration := flags.Duration("duration", 0, "length to render, overriding -loops")
+	fade := flags.Duration("fade", 0, "fade out over the last part of the output")
+	filter := flags.String("filter", "off", "output filter: off, dc, st or tv")
+	pan := flags.String("pan", "mono", "stereo layout: mono, abc, acb or A,B,C positions from 0 (left) to 1 (right)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: menu render [flags] in.ym out.wav")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("render needs an input and an output file")
+	}
+	if *rate <= 0 || *loops < 1 {
+		return errors.New("rate and loops must be positive")
+	}
+	panning, err := ParsePanning(*pan)
+	if err != nil {
+		return err
+	}
+	outputFilter, err := ParseOutputFilter(*filter)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	player, err := NewYMPlayer(data, *rate, *loops > 1 || *duration > 0)
+	if err != nil {
+		return err
+	}
+	defer player.Close()
+	player.SetPanning(panning)
+	player.SetFilter(outputFilter)
+
+	samples := int64(durationToSamples(*duration, *rate))
+	if *duration <= 0 {
+		samples = player.loopedLength(*loops)
+	}
+
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	written, err := writeWAV(out, player, samples, *fade, *rate)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s, %d Hz\n", flags.Arg(1), formatDuration(samplesToDuration(written, *rate)), *rate)
+	return nil
+}
+
+// loopedLength returns the number of samples in the given number of plays
+// of the tune, later plays starting from the loop frame.
+func (y *YMPlayer) loopedLength(loops int) int64 {
+	start, end := y.loopBounds()
+	return end + int64(loops-1)*(end-start)
+}
+
+// writeWAV renders up to samples stereo samples, fading out over the last
+// fade of them, and returns how many were written. The header sizes are
+// filled in once the length is known, since a tune may end early.
+func writeWAV(out io.WriteSeeker, player *YMPlayer, samples int64, fade time.Duration, rate int) (int64, error) {
+	if _, err := out.Write(make([]byte, wavHeaderSize)); err != nil {
+		return 0, err
+	}
+
+	fadeStart := max(samples-int64(durationToSamples(fade, rate)), 0)
+	buf := make([]byte, 4096*bytesPerSample)
+	var written int64
+	for written < samples {
+		chunk := min(samples-written, int64(len(buf)/bytesPerSample))
+		// Split the read where the fade begins so it starts on the exact sample.
+		if fade > 0 && written < fadeStart {
+			chunk = min(chunk, fadeStart-written)
+		}
+		if fade > 0 && written == fadeStart {
+			player.FadeTo(0, fade)
+		}
+		n, err := player.Read(buf[:chunk*bytesPerSample])
+		if _, werr := out.Write(buf[:n]); werr != nil {
+			return written, werr
+		}
+		written += int64(n / bytesPerSample)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	header := make([]byte, wavHeaderSize)
+	dataSize := uint32(written * bytesPerSample)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+dataSize)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], 2)
+	binary.LittleEndian.PutUint32(header[24:], uint32(rate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(rate*bytesPerSample))
+	binary.LittleEndian.PutUint16(header[32:], bytesPerSample)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], dataSize)
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return written, err
+	}
+	_, err := out.Write(header)
+	return written, err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestRenderDeterministic renders the same stretch of a tune twice, with
+// the options that carry state across reads, and expects identical files.
+func TestRenderDeterministic(t *testing.T) {
+	loadMenuTune(t)
+	const duration, rate = 3 * time.Second, 22050
+	dir := t.TempDir()
+	var outputs [2][]byte
+	for i := range outputs {
+		out := filepath.Join(dir, fmt.Sprintf("out%d.wav", i))
+		args := []string{"-rate", strconv.Itoa(rate), "-duration", duration.String(), "-fade", "1s",
+			"-pan", "abc", "-filter", "st", menuTune, out}
+		if err := renderCommand(args); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[i] = data
+	}
+
+	want := wavHeaderSize + durationToSamples(duration, rate)*bytesPerSample
+	if len(outputs[0]) != want {
+		t.Fatalf("rendered %d bytes, want %d", len(outputs[0]), want)
+	}
+	if size := binary.LittleEndian.Uint32(outputs[0][40:]); int(size) != want-wavHeaderSize {
+		t.Errorf("header gives %d bytes of data, want %d", size, want-wavHeaderSize)
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("rendering the same tune twice gave different output")
+	}
+}
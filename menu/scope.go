@@ -0,0 +1,170 @@
+package main
+
+import (
+	"image/color"
+	"sync/atomic"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// scopeRingSize holds enough audio to reach back past the output
+	// latency. It must be a power of two.
+	scopeRingSize = 1 << 14
+	// scopeWindow is how much audio the oscilloscope shows.
+	scopeWindow = 20 * time.Millisecond
+	// vuDecay is how much of a meter's level is left after one update.
+	vuDecay = 0.8
+
+	scopeX      = 8
+	scopeY      = gameHeight - scopeHeight - 8
+	scopeWidth  = 256
+	scopeHeight = 64
+	vuWidth     = 12
+	vuGap       = 4
+)
+
+// ScopeRing passes rendered audio from the audio thread to the visualiser
+// without locking. Each sample, with the voice levels, is packed into one
+// word so the reader never sees half of one; it may see a sample the
+// writer has just replaced, which only shows as a glitch in the scope.
+type ScopeRing struct {
+	samples [scopeRingSize]atomic.Uint64
+	written atomic.Uint64
+}
+
+type ScopeSample struct {
+	Mix    int16
+	Levels [ayVoices]int16
+}
+
+func (r *ScopeRing) push(mix int16, levels [ayVoices]int32) {
+	v := uint64(uint16(mix))
+	for i, level := range levels {
+		v |= uint64(uint16(level)) << (16 * (i + 1))
+	}
+	pos := r.written.Load()
+	r.samples[pos&(scopeRingSize-1)].Store(v)
+	r.written.Store(pos + 1)
+}
+
+// Snapshot fills dst with the latest samples but the last delay, oldest
+// first, and returns how many were available.
+func (r *ScopeRing) Snapshot(dst []ScopeSample, delay int) int {
+	end := int64(r.written.Load()) - int64(delay)
+	n := min(int64(len(dst)), end, scopeRingSize-int64(delay))
+	if n <= 0 {
+		return 0
+	}
+	start := end - n
+	for i := range dst[:n] {
+		v := r.samples[uint64(start+int64(i))&(scopeRingSize-1)].Load()
+		dst[i].Mix = int16(v)
+		for voice := range dst[i].Levels {
+			dst[i].Levels[voice] = int16(v >> (16 * (voice + 1)))
+		}
+	}
+	return int(n)
+}
+
+// scopeSource is implemented by sources that can feed a ScopeRing.
+type scopeSource interface {
+	SetScope(ring *ScopeRing)
+}
+
+// Visualiser draws VU meters for the three voices and an oscilloscope of
+// the tune being heard, over the menu scene.
+type Visualiser struct {
+	On bool
+
+	ring    ScopeRing
+	tune    scopeSource
+	samples []ScopeSample
+	count   int
+	vu      [ayVoices]float64
+}
+
+// updateVisualiser toggles the overlay with V and keeps the ring fed by
+// whichever tune is heard.
+func (g *Game) updateVisualiser() {
+	v := &g.visualiser
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		v.On = !v.On
+	}
+	tune, _ := g.heardTune().(scopeSource)
+	if !v.On {
+		tune = nil
+	}
+	if tune != v.tune {
+		if v.tune != nil {
+			v.tune.SetScope(nil)
+		}
+		if tune != nil {
+			tune.SetScope(&v.ring)
+		}
+		v.tune = tune
+	}
+	if tune == nil {
+		v.count = 0
+		v.vu = [ayVoices]float64{}
+		return
+	}
+
+	if v.samples == nil {
+		v.samples = make([]ScopeSample, durationToSamples(scopeWindow, g.audioConfig.SampleRate))
+	}
+	delay := durationToSamples(g.audioLatency(), g.audioConfig.SampleRate)
+	v.count = v.ring.Snapshot(v.samples, delay)
+	var peaks [ayVoices]float64
+	for _, s := range v.samples[:v.count] {
+		for voice, level := range s.Levels {
+			peaks[voice] = max(peaks[voice], float64(level)/float64(ayVolume[15]))
+		}
+	}
+	for voice := range v.vu {
+		v.vu[voice] = max(v.vu[voice]*vuDecay, min(peaks[voice], 1))
+	}
+}
+
+func (g *Game) drawVisualiser(dst *ebiten.Image) {
+	v := &g.visualiser
+	if !v.On {
+		return
+	}
+	metersWidth := ayVoices * (vuWidth + vuGap)
+	ebitenutil.DrawRect(dst, scopeX-4, scopeY-4, float64(metersWidth+scopeWidth+8), scopeHeight+8, color.RGBA{0, 0, 0, 180})
+
+	for voice, level := range v.vu {
+		x := float32(scopeX + voice*(vuWidth+vuGap))
+		h := float32(level * scopeHeight)
+		vector.DrawFilledRect(dst, x, scopeY, vuWidth, scopeHeight, color.RGBA{0x20, 0x20, 0x20, 0xff}, false)
+		vector.DrawFilledRect(dst, x, scopeY+scopeHeight-h, vuWidth, h, vuColour(level), false)
+	}
+
+	x0 := float32(scopeX + metersWidth)
+	mid := float32(scopeY + scopeHeight/2)
+	vector.StrokeLine(dst, x0, mid, x0+scopeWidth, mid, 1, color.RGBA{0x30, 0x30, 0x60, 0xff}, false)
+	if v.count < 2 {
+		return
+	}
+	scale := float32(scopeHeight/2) / 32768
+	prevX, prevY := x0, mid-float32(v.samples[0].Mix)*scale
+	for px := 1; px < scopeWidth; px++ {
+		s := v.samples[px*(v.count-1)/(scopeWidth-1)]
+		x, y := x0+float32(px), mid-float32(s.Mix)*scale
+		vector.StrokeLine(dst, prevX, prevY, x, y, 1, color.RGBA{0x80, 0xff, 0x80, 0xff}, false)
+		prevX, prevY = x, y
+	}
+}
+
+// vuColour goes from green through yellow to red as a meter fills.
+func vuColour(level float64) color.RGBA {
+	if level < 0.5 {
+		return color.RGBA{uint8(level * 2 * 255), 0xff, 0, 0xff}
+	}
+	return color.RGBA{0xff, uint8((1 - level) * 2 * 255), 0, 0xff}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Screen is a demo screen opened through one of the menu doors. Screens
+// that hold resources may also implement Close, which is called when the
+// player walks back out, and screens with their own tune implement Music,
+// which overrides the tune declared by the door.
+type Screen interface {
+	Update() error
+	Draw(dst *ebiten.Image)
+}
+
+type screenFactory func(g *Game) Screen
+
+var screenFactories = map[string]screenFactory{
+	"BIG_SPRITE":    newBigSpriteScreen,
+	"COLORSHOCK_II": newColorShockScreen,
+	"SPREADPOINT":   newSpreadPointScreen,
+	"FULLSCREEN":    newFullscreenScreen,
+
+	"KNUCKLE_BUSTER": effectPackScreen("KNUCKLE_BUSTER"),
+	"DOC":            effectPackScreen("DOC"),
+	"NO_NAME_1":      effectPackScreen("NO_NAME_1"),
+	"NO_NAME_2":      effectPackScreen("NO_NAME_2"),
+
+	"CREDITS": newCreditsScreen,
+}
+
+// autoPilotScreenDuration is how long a screen entered by the autopilot is
+// shown before going back to the menu.
+const autoPilotScreenDuration = 60 * 20
+
+type ScreenState struct {
+	Active    Screen
+	Name      string
+	Music     string
+	Timer     int
+	AutoPilot bool
+}
+
+func screenAssetPath(screen, name string) string {
+	dir := strings.ToLower(strings.ReplaceAll(screen, "_", ""))
+	return filepath.Join("assets", dir, name)
+}
+
+func (g *Game) openScreen(name string) bool {
+	factory, ok := screenFactories[name]
+	if !ok {
+		return false
+	}
+	screen := factory(g)
+	if screen == nil {
+		return false
+	}
+	g.screen = ScreenState{
+		Active:    screen,
+		Name:      name,
+		Music:     doorMusic(name),
+		AutoPilot: g.autoPilot.ActivateIn <= 0,
+	}
+	if m, ok := screen.(interface{ Music() string }); ok && m.Music() != "" {
+		g.screen.Music = m.Music()
+	}
+	return true
+}
+
+func (g *Game) closeScreen() {
+	if closer, ok := g.screen.Active.(interface{ Close() }); ok {
+		closer.Close()
+	}
+	if !g.screen.AutoPilot {
+		g.autoPilot.ActivateIn = autoPilotActivateDuration
+	}
+	g.screen = ScreenState{}
+}
+
+func (g *Game) updateScreen() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.screen.AutoPilot = false
+		g.closeScreen()
+		return nil
+	}
+	if len(inpututil.AppendPressedKeys(nil)) > 0 {
+		g.screen.AutoPilot = false
+	}
+	if g.screen.AutoPilot {
+		g.screen.Timer++
+		if g.screen.Timer >= autoPilotScreenDuration {
+			g.closeScreen()
+			return nil
+		}
+	}
+	return g.screen.Active.Update()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+const (
+	sfxDir    = "assets/sfx"
+	sfxConfig = "sfx.json"
+	// sfxSteal is how quickly a voice is faded out to make room for a new
+	// effect, short enough to be heard as a cut but without a click.
+	sfxSteal = 10 * time.Millisecond
+)
+
+// sfxStep is one 50 Hz frame of a synthesised effect on a single AY voice.
+// A zero Tone or Noise period leaves that generator off.
+type sfxStep struct {
+	Tone   int
+	Noise  int
+	Volume int
+}
+
+// synthEffects are played when there is no WAV file for an effect.
+var synthEffects = map[string][]sfxStep{
+	"thrust": {{0, 6, 12}, {0, 7, 10}, {0, 8, 8}, {0, 9, 6}, {0, 10, 4}, {0, 12, 2}},
+	"land":   {{0x400, 24, 15}, {0x480, 26, 12}, {0x500, 28, 9}, {0x580, 30, 6}, {0x600, 31, 3}},
+	"door": {
+		{0x180, 0, 13}, {0x168, 0, 13}, {0x150, 0, 13}, {0x138, 0, 13}, {0x120, 0, 13}, {0x108, 0, 13},
+		{0xf0, 0, 12}, {0xd8, 0, 11}, {0xc0, 0, 10}, {0xa8, 0, 8}, {0x90, 0, 6}, {0x78, 0, 3},
+	},
+}
+
+// SFXConfig is read from sfx.json. Volumes are per effect, from 0 to 1.
+type SFXConfig struct {
+	MaxVoices int                `json:"maxVoices"`
+	Volumes   map[string]float64 `json:"volumes"`
+}
+
+var defaultSFXConfig = SFXConfig{
+	MaxVoices: 4,
+	Volumes:   map[string]float64{"thrust": 0.3, "land": 0.6, "door": 0.7},
+}
+
+type soundEffect struct {
+	pcm    []byte
+	volume float64
+}
+
+type sfxVoice struct {
+	name    string
+	channel *MixerChannel
+}
+
+// SFX plays the game's sound effects on their own mixer channels, next to
+// the music. At most MaxVoices effects sound at once; starting another one
+// cuts the oldest.
+type SFX struct {
+	On        bool
+	MaxVoices int
+
+	mixer   *Mixer
+	effects map[string]*soundEffect
+	voices  []sfxVoice
+}
+
+func NewSFX(mixer *Mixer, dir string, sampleRate int) *SFX {
+	config := loadSFXConfig(filepath.Join(dir, sfxConfig))
+	s := &SFX{On: true, MaxVoices: config.MaxVoices, mixer: mixer, effects: make(map[string]*soundEffect)}
+	for name, steps := range synthEffects {
+		pcm, err := loadWAV(filepath.Join(dir, name+".wav"), sampleRate)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("failed to load sound %s (%v), using the synthesised one", name, err)
+			}
+			pcm = synthesise(steps, sampleRate)
+		}
+		volume, ok := config.Volumes[name]
+		if !ok {
+			volume = defaultSFXConfig.Volumes[name]
+		}
+		s.effects[name] = &soundEffect{pcm: pcm, volume: volume}
+	}
+	return s
+}
+
+func loadSFXConfig(path string) SFXConfig {
+	config := SFXConfig{MaxVoices: defaultSFXConfig.MaxVoices}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("failed to parse %s (%v), using defaults", path, err)
+		return defaultSFXConfig
+	}
+	if config.MaxVoices <= 0 {
+		config.MaxVoices = defaultSFXConfig.MaxVoices
+	}
+	return config
+}
+
+// loadWAV decodes a WAV file to 16-bit stereo at the given rate.
+func loadWAV(path string, sampleRate int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(stream)
+}
+
+// synthesise renders an effect on voice A of an AYChip, as 16-bit stereo.
+func synthesise(steps []sfxStep, sampleRate int) []byte {
+	chip := NewAYChip(ayClock, sampleRate)
+	frame := sampleRate / ymFrameRate
+	pcm := make([]byte, 0, len(steps)*frame*bytesPerSample)
+	for _, step := range steps {
+		mixer := byte(0x3f)
+		if step.Tone > 0 {
+			mixer &^= 1
+			chip.WriteRegister(0, byte(step.Tone))
+			chip.WriteRegister(1, byte(step.Tone>>8))
+		}
+		if step.Noise > 0 {
+			mixer &^= 8
+			chip.WriteRegister(6, byte(step.Noise))
+		}
+		chip.WriteRegister(7, mixer)
+		chip.WriteRegister(8, byte(step.Volume&15))
+		for i := 0; i < frame; i++ {
+			v := chip.next(allVoices)
+			pcm = append(pcm, byte(v), byte(v>>8), byte(v), byte(v>>8))
+		}
+	}
+	return pcm
+}
+
+// Play starts an effect, cutting the oldest one if all voices are busy.
+func (s *SFX) Play(name string) {
+	if s == nil || !s.On {
+		return
+	}
+	effect := s.effects[name]
+	if effect == nil || effect.volume <= 0 {
+		return
+	}
+	s.prune()
+	for len(s.voices) >= s.MaxVoices {
+		s.voices[0].channel.Stop(sfxSteal)
+		s.voices = s.voices[1:]
+	}
+	channel := s.mixer.Add(bytes.NewReader(effect.pcm), effect.volume)
+	s.voices = append(s.voices, sfxVoice{name: name, channel: channel})
+}
+
+// Sustain plays an effect unless it is still sounding, for sounds that
+// repeat for as long as something goes on, like the thrust.
+func (s *SFX) Sustain(name string) {
+	if s == nil {
+		return
+	}
+	s.prune()
+	for _, v := range s.voices {
+		if v.name == name {
+			return
+		}
+	}
+	s.Play(name)
+}
+
+func (s *SFX) SetOn(on bool) {
+	s.On = on
+	if on {
+		return
+	}
+	for _, v := range s.voices {
+		v.channel.Stop(sfxSteal)
+	}
+	s.voices = nil
+}
+
+// prune forgets the effects that have finished.
+func (s *SFX) prune() {
+	voices := s.voices[:0]
+	for _, v := range s.voices {
+		if !v.channel.Removed() {
+			voices = append(voices, v)
+		}
+	}
+	clear(s.voices[len(voices):])
+	s.voices = voices
+}
+
+// updateSFX plays the effects for the dude. It runs after integrate,
+// comparing the model with how it was before.
+func (g *Game) updateSFX(before Model) {
+	if g.sfx == nil {
+		return
+	}
+	m := g.model
+	if m.Thrusting && g.thrustOff == 0 {
+		g.sfx.Sustain("thrust")
+	}
+	if m.JustLanded == 1 && before.JustLanded != 1 {
+		g.sfx.Play("land")
+	}
+}
@@ -2,197 +2,170 @@ package main
 
 import (
 	"math"
+	"path/filepath"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+const (
+	// sineSpan is how far apart, in carebear widths, the first and last of
+	// the original twelve letters are. Longer rows are squeezed into it.
+	sineSpan  = 11
+	sinePulse = 0.06
+)
+
+// SineSprites draws a row of sprites along the sine patterns of Config:
+// the carebear letters, or any text in a sprite font. Pulse, from 0 to 1,
+// widens the patterns a little to follow the music.
 type SineSprites struct {
-	Tiles *TileSet
+	Sprites []*ebiten.Image
+	Config  SineConfig
+	Pulse   float64
+
+	// letters are the places of Sprites in the row.
+	letters []sineLetter
 }
 
-func (s *SineSprites) Draw(dst *ebiten.Image, t float64) {
-	if s == nil || s.Tiles == nil {
-		return
+// NewSineSprites picks the sprites for config from its text and font, or
+// from the carebear tiles.
+func NewSineSprites(config SineConfig, carebears *TileSet) *SineSprites {
+	s := &SineSprites{Config: config}
+	var widths []float64
+	add := func(sprite *ebiten.Image, width int) {
+		s.Sprites = append(s.Sprites, sprite)
+		widths = append(widths, float64(width)/carebearTileW)
 	}
-
-	const (
-		letterCount       = 12
-		animationCount    = 7
-		animationDuration = 8.0
-		scrollDuration    = 1.0
-		scrollIndex       = 0.5
-	)
-
-	cycle := animationDuration + (scrollDuration * 2)
-	maxTime := float64(animationCount) * cycle
-	if t >= maxTime {
-		t = math.Mod(t, maxTime)
+	if config.Text == "" {
+		for _, tile := range config.Tiles {
+			sprite := carebears.Tile(tile)
+			add(sprite, sprite.Bounds().Dx())
+		}
+		s.letters = sineRow(widths)
+		return s
 	}
 
-	// Make sure we have enough space to subtract from time
-	t += scrollDuration
+	var font *BitmapFont
+	if config.Font == "" {
+		font = NewBitmapFont(makePlaceholderFont(), placeholderGlyphW, placeholderGlyphH)
+	} else {
+		font = loadBitmapFont(filepath.Join("assets", "menu", config.Font), config.GlyphWidth, config.GlyphHeight)
+	}
+	for _, r := range config.Text {
+		switch c := int(r) - fontFirstChar; {
+		case c == 0:
+			// Spaces keep their place in the row but draw nothing.
+			add(nil, font.Glyphs.TileW)
+		case c > 0 && c < len(font.Glyphs.Tiles):
+			sprite := font.Glyphs.Tile(c)
+			add(sprite, sprite.Bounds().Dx())
+		}
+		// Anything else isn't in the font and is left out.
+	}
+	s.letters = sineRow(widths)
+	return s
+}
 
-	timerCycle := math.Floor(deriveFromTime(t, cycle, 0, cycle))
-	xDisplacement := 0.0
-	if timerCycle <= (scrollDuration - scrollIndex) {
-		xDisplacement = -float64(dst.Bounds().Dx()) * deriveFromTime(t, scrollDuration, 0, 1)
-	} else if timerCycle <= (scrollDuration + scrollDuration - scrollIndex) {
-		xDisplacement = float64(dst.Bounds().Dx()) * deriveFromTime(t, scrollDuration, 1, 0)
+func (s *SineSprites) Draw(dst *ebiten.Image, t float64) {
+	if s == nil || len(s.Sprites) == 0 || len(s.Config.Animations) == 0 {
+		return
 	}
 
-	animation := int(math.Floor((t-scrollDuration)/cycle)) % animationCount
+	var frame sineFrame
+	if s.Config.Transition == SineMorph {
+		frame = s.morphFrame(t)
+	} else {
+		frame = s.slideFrame(t, float64(dst.Bounds().Dx()))
+	}
 
 	centerX := float64(dst.Bounds().Dx()) * 0.5
 	centerY := float64(dst.Bounds().Dy()) * 0.5
-	width := centerX * 0.9
-	height := centerY * 0.88
-
-	centerSpriteX := float64(carebearTileW) / 2
-	centerSpriteY := float64(carebearTileH) / 2
-
-	for i := 0; i < letterCount; i++ {
-		p := sineSpritePoint(animation, t, i, width, height)
-		p.x += centerX + xDisplacement
+	width := centerX * 0.9 * (1 + sinePulse*s.Pulse)
+	height := centerY * 0.88 * (1 + sinePulse*s.Pulse)
+
+	for i, sprite := range s.Sprites {
+		if sprite == nil {
+			continue
+		}
+		p := frame.point(s.letters[i], width, height)
+		p.x += centerX + frame.shift
 		p.y += centerY
-		p.x = math.Floor(p.x) - centerSpriteX
-		p.y = math.Floor(p.y) - centerSpriteY
+		p.x = math.Floor(p.x) - float64(sprite.Bounds().Dx())/2
+		p.y = math.Floor(p.y) - float64(sprite.Bounds().Dy())/2
 
-		tile := s.Tiles.Tile(i)
 		var op ebiten.DrawImageOptions
 		op.GeoM.Translate(p.x, p.y)
-		dst.DrawImage(tile, &op)
+		dst.DrawImage(sprite, &op)
 	}
 }
 
-type sinePoint struct {
-	x float64
-	y float64
+// sineFrame is where the sprites are in the cycle of animations: on from at
+// time t, blended by mix towards to and moved right by shift pixels.
+type sineFrame struct {
+	from, to SineAnimation
+	t        float64
+	mix      float64
+	shift    float64
 }
 
-func sineSpritePoint(animation int, t float64, i int, width, height float64) sinePoint {
-	switch animation {
-	case 0:
-		return sineFunc0(t, i, width, height)
-	case 1:
-		return sineFunc1(t, i, width, height)
-	case 2:
-		return sineFunc2(t, i, width, height)
-	case 3:
-		return sineFunc3(t, i, width, height)
-	case 4:
-		return sineFunc4(t, i, width, height)
-	case 5:
-		return sineFunc5(t, i, width, height)
-	default:
-		return sineFunc6(t, i, width, height)
+func (f sineFrame) point(l sineLetter, width, height float64) sinePoint {
+	p := f.from.point(f.t, l, width, height)
+	if f.mix > 0 {
+		q := f.to.point(f.t, l, width, height)
+		p.x += (q.x - p.x) * f.mix
+		p.y += (q.y - p.y) * f.mix
 	}
+	return p
 }
 
-func sineFunc0(t float64, i int, width, height float64) sinePoint {
-	speedX := 25.0
-	speedY := 4.5
-	spacingY := 0.15
-	spacingX := 0.3
-
-	w := width * 0.5 * 0.5
-	h := height * 0.25 * 0.25
-
-	x := ((float64(i) - (12-1)/2.0) * spacingX) * w
-	y := math.Cos((t-float64(i)*spacingY)*speedY) * h
-
-	spinX := 6.1
-	o := math.Sin(t*spinX + float64(i)*spinX*3)
-	x += o * speedX
-
-	x *= 2.1
-	y *= 3
-
-	return sinePoint{x: x, y: y}
-}
+// slideFrame plays each animation for the configured duration, sliding the
+// letters off the screen to the left and back on with the next one.
+func (s *SineSprites) slideFrame(t, screenWidth float64) sineFrame {
+	const scrollIndex = 0.5
 
-func sineFunc1(t float64, i int, width, height float64) sinePoint {
-	speedX := 2.5 * 0.7
-	speedY := 5.0 * 0.7
-	spacing := 0.1
-	twistSpeed := 1.6 * 0.7
-	twist := (math.Sin(t*twistSpeed) + math.Cos(t*twistSpeed)) * 0.75
-
-	x := math.Sin((t-float64(i)*spacing)*speedX) * width * 1.05
-	y := math.Sin((t-float64(i)*spacing)*speedY) * height * twist
-
-	return sinePoint{x: x, y: y}
-}
-
-func sineFunc2(t float64, i int, width, height float64) sinePoint {
-	speedX := 4.0
-	speedY := 3.0
-	spacing := 0.07
-	twistSpeed := 2.0
-	twist := (math.Sin(t*twistSpeed) + math.Cos(t*twistSpeed)) * 0.75
-
-	x := math.Sin((t-float64(i)*spacing)*speedX) * width * 1.05
-	y := math.Sin((t-float64(i)*spacing)*speedY) * height * twist
-	x *= math.Cos((t - float64(i)) * 0.25)
-
-	return sinePoint{x: x, y: y}
-}
-
-func sineFunc3(t float64, i int, width, height float64) sinePoint {
-	speedX := 4.0 * 0.7
-	speedY := 3.0 * 0.7
-	spacing := 0.07
-	twistSpeed := 2.0
-	twist := (math.Sin(t*twistSpeed) + math.Cos(t*twistSpeed)) * 0.73
-
-	x := math.Sin((t-float64(i)*spacing)*speedX) * width * twist
-	y := math.Cos((t-float64(i)*spacing)*speedY) * height
-	y *= math.Sin((t - float64(i)) * 0.25)
-
-	return sinePoint{x: x, y: y}
-}
-
-func sineFunc4(t float64, i int, width, height float64) sinePoint {
-	speedX := 1.0
-	speedY := 1.2
-	spacing := 0.1
+	animations := s.Config.Animations
+	scrollDuration := s.Config.Scroll
+	cycle := s.Config.Duration + (scrollDuration * 2)
+	maxTime := float64(len(animations)) * cycle
+	if t >= maxTime {
+		t = math.Mod(t, maxTime)
+	}
 
-	twistSpeedY := 4.0
-	twistSpeedX := 4.5
-	twistY := (1 - math.Sin((t-float64(i)*spacing)*twistSpeedY)) * 0.52
-	twistX := (1 - math.Sin((t-float64(i)*spacing)*twistSpeedX)) * 0.52
+	// Make sure we have enough space to subtract from time
+	t += scrollDuration
 
-	x := math.Sin((t-float64(i)*spacing)*speedX) * width * twistX
-	y := math.Cos((t-float64(i)*spacing)*speedY) * height * twistY
+	timerCycle := math.Floor(deriveFromTime(t, cycle, 0, cycle))
+	xDisplacement := 0.0
+	if timerCycle <= (scrollDuration - scrollIndex) {
+		xDisplacement = -screenWidth * deriveFromTime(t, scrollDuration, 0, 1)
+	} else if timerCycle <= (scrollDuration + scrollDuration - scrollIndex) {
+		xDisplacement = screenWidth * deriveFromTime(t, scrollDuration, 1, 0)
+	}
 
-	return sinePoint{x: x, y: y}
+	animation := animations[int(math.Floor((t-scrollDuration)/cycle))%len(animations)]
+	return sineFrame{from: animation, to: animation, t: t, shift: xDisplacement}
 }
 
-func sineFunc5(t float64, i int, width, height float64) sinePoint {
-	speedX := 3.5 * 0.7
-	speedY := 3.5 * 0.7
-	spacing := 0.1
-	h := height * 0.9
-	twistSpeed := 3.0 * 0.5
-	twist := (math.Sin(t*twistSpeed) + math.Cos(t*twistSpeed)) * 0.82
-
-	x := math.Cos((t-float64(i)*spacing)*speedX) * width * 1.05
-	y := math.Sin((t-float64(i)*spacing)*speedY) * h * twist
-
-	return sinePoint{x: x, y: y}
+// morphFrame plays each animation for the configured duration, then moves
+// every letter from its place on that curve to its place on the next one,
+// eased in and out, while both keep moving.
+func (s *SineSprites) morphFrame(t float64) sineFrame {
+	animations := s.Config.Animations
+	cycle := s.Config.Duration + s.Config.Morph
+	index := int(math.Floor(t/cycle)) % len(animations)
+	frame := sineFrame{
+		from: animations[index],
+		to:   animations[(index+1)%len(animations)],
+		t:    t,
+	}
+	if into := math.Mod(t, cycle) - s.Config.Duration; into > 0 {
+		frame.mix = easeInOut(into / s.Config.Morph)
+	}
+	return frame
 }
 
-func sineFunc6(t float64, i int, width, height float64) sinePoint {
-	speedX := 4.0 * 0.7
-	speedY := 3.0 * 0.7
-	spacing := 0.1
-	twistSpeed := 3.0
-	twist := (math.Sin(t*twistSpeed) + math.Cos(t*twistSpeed)) * 0.75
-
-	x := math.Cos((t-float64(i)*spacing)*speedX) * width * 1.04
-	y := math.Sin((t-float64(i)*spacing)*speedY) * height * twist
-	x *= math.Sin((t - float64(i)*spacing) * 0.5)
-
-	return sinePoint{x: x, y: y}
+type sinePoint struct {
+	x float64
+	y float64
 }
 
 func deriveFromTime(time, duration, min, max float64) float64 {
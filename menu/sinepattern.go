@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+)
+
+// sineConfig is read from the menu assets when present.
+const sineConfig = "sine.json"
+
+// SineWave is Amp * f((t - i*Delay) * Speed) for letter i, where f is "sin"
+// or "cos". "spread" ignores time and gives the letter's distance from the
+// middle of the row, so Amp is the gap between letters. An Amp of 0 counts
+// as 1. Letters are counted in carebear widths, so smaller sprites sit
+// closer together.
+type SineWave struct {
+	Func  string  `json:"func"`
+	Speed float64 `json:"speed"`
+	Delay float64 `json:"delay"`
+	Amp   float64 `json:"amp"`
+}
+
+// SineTwist is Bias plus the sum of Waves. It scales an axis, so patterns
+// can squash and turn over time.
+type SineTwist struct {
+	Bias  float64    `json:"bias"`
+	Waves []SineWave `json:"waves"`
+}
+
+// SineAxis places the letters along x or y:
+//
+//	(sum(Waves) * Size * half the pattern area + sum(Wobble)) * twists * Scale
+//
+// Wobble is in pixels rather than relative to the pattern area. A Scale of 0
+// counts as 1.
+type SineAxis struct {
+	Size   float64     `json:"size"`
+	Waves  []SineWave  `json:"waves"`
+	Wobble []SineWave  `json:"wobble"`
+	Twists []SineTwist `json:"twists"`
+	Scale  float64     `json:"scale"`
+}
+
+type SineAnimation struct {
+	Name string   `json:"name"`
+	X    SineAxis `json:"x"`
+	Y    SineAxis `json:"y"`
+}
+
+// Transitions between sine sprite animations.
+const (
+	// SineSlide slides the letters off the screen and back on with the
+	// next animation.
+	SineSlide = "slide"
+	// SineMorph moves each letter from the old curve onto the new one.
+	SineMorph = "morph"
+)
+
+// SineConfig lists the animations the sine sprites cycle through. Each
+// plays for Duration seconds. With the slide transition the letters take
+// Scroll seconds to leave and come back; with the morph transition they
+// take Morph seconds to move onto the next curve.
+type SineConfig struct {
+	Duration   float64         `json:"duration"`
+	Transition string          `json:"transition"`
+	Scroll     float64         `json:"scroll"`
+	Morph      float64         `json:"morph"`
+	Animations []SineAnimation `json:"animations"`
+
+	// Text, when set, is drawn one character per sprite from Font, a glyph
+	// sheet in the menu assets laid out like the other bitmap fonts. Without
+	// a Font the built-in one is used. Characters the font doesn't have are
+	// left out.
+	Text        string `json:"text"`
+	Font        string `json:"font"`
+	GlyphWidth  int    `json:"glyphWidth"`
+	GlyphHeight int    `json:"glyphHeight"`
+	// Tiles are the carebear tiles drawn when there is no Text.
+	Tiles []int `json:"tiles"`
+}
+
+func sinWave(speed, delay, amp float64) SineWave {
+	return SineWave{Func: "sin", Speed: speed, Delay: delay, Amp: amp}
+}
+
+func cosWave(speed, delay, amp float64) SineWave {
+	return SineWave{Func: "cos", Speed: speed, Delay: delay, Amp: amp}
+}
+
+// turn is the (sin + cos) * amp turn most of the patterns use.
+func turn(speed, amp float64) SineTwist {
+	return SineTwist{Waves: []SineWave{sinWave(speed, 0, amp), cosWave(speed, 0, amp)}}
+}
+
+// defaultSineConfig holds the seven patterns of the original menu.
+var defaultSineConfig = SineConfig{
+	Duration:    8,
+	Transition:  SineSlide,
+	Scroll:      1,
+	Morph:       2,
+	GlyphWidth:  16,
+	GlyphHeight: 16,
+	Tiles:       []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	Animations: []SineAnimation{
+		{
+			Name: "wave",
+			X: SineAxis{
+				Size:   0.25,
+				Waves:  []SineWave{{Func: "spread", Amp: 0.3}},
+				Wobble: []SineWave{sinWave(6.1, -3, 25)},
+				Scale:  2.1,
+			},
+			Y: SineAxis{Size: 0.0625, Waves: []SineWave{cosWave(4.5, 0.15, 1)}, Scale: 3},
+		},
+		{
+			Name: "figure eight",
+			X:    SineAxis{Size: 1.05, Waves: []SineWave{sinWave(2.5*0.7, 0.1, 1)}},
+			Y:    SineAxis{Size: 1, Waves: []SineWave{sinWave(5.0*0.7, 0.1, 1)}, Twists: []SineTwist{turn(1.6*0.7, 0.75)}},
+		},
+		{
+			Name: "lissajous",
+			X: SineAxis{
+				Size:   1.05,
+				Waves:  []SineWave{sinWave(4, 0.07, 1)},
+				Twists: []SineTwist{{Waves: []SineWave{cosWave(0.25, 1, 1)}}},
+			},
+			Y: SineAxis{Size: 1, Waves: []SineWave{sinWave(3, 0.07, 1)}, Twists: []SineTwist{turn(2, 0.75)}},
+		},
+		{
+			Name: "tumble",
+			X:    SineAxis{Size: 1, Waves: []SineWave{sinWave(4.0*0.7, 0.07, 1)}, Twists: []SineTwist{turn(2, 0.73)}},
+			Y: SineAxis{
+				Size:   1,
+				Waves:  []SineWave{cosWave(3.0*0.7, 0.07, 1)},
+				Twists: []SineTwist{{Waves: []SineWave{sinWave(0.25, 1, 1)}}},
+			},
+		},
+		{
+			Name: "spiral",
+			X: SineAxis{
+				Size:   1,
+				Waves:  []SineWave{sinWave(1, 0.1, 1)},
+				Twists: []SineTwist{{Bias: 0.52, Waves: []SineWave{sinWave(4.5, 0.1, -0.52)}}},
+			},
+			Y: SineAxis{
+				Size:   1,
+				Waves:  []SineWave{cosWave(1.2, 0.1, 1)},
+				Twists: []SineTwist{{Bias: 0.52, Waves: []SineWave{sinWave(4, 0.1, -0.52)}}},
+			},
+		},
+		{
+			Name: "circle",
+			X:    SineAxis{Size: 1.05, Waves: []SineWave{cosWave(3.5*0.7, 0.1, 1)}},
+			Y:    SineAxis{Size: 0.9, Waves: []SineWave{sinWave(3.5*0.7, 0.1, 1)}, Twists: []SineTwist{turn(3.0*0.5, 0.82)}},
+		},
+		{
+			Name: "butterfly",
+			X: SineAxis{
+				Size:   1.04,
+				Waves:  []SineWave{cosWave(4.0*0.7, 0.1, 1)},
+				Twists: []SineTwist{{Waves: []SineWave{sinWave(0.5, 0.1, 1)}}},
+			},
+			Y: SineAxis{Size: 1, Waves: []SineWave{sinWave(3.0*0.7, 0.1, 1)}, Twists: []SineTwist{turn(3, 0.75)}},
+		},
+	},
+}
+
+func loadSineConfig(path string) SineConfig {
+	var config SineConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaultSineConfig
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("failed to parse %s (%v), using defaults", path, err)
+		return defaultSineConfig
+	}
+	if config.Duration <= 0 {
+		config.Duration = defaultSineConfig.Duration
+	}
+	switch config.Transition {
+	case SineSlide, SineMorph:
+	case "":
+		config.Transition = defaultSineConfig.Transition
+	default:
+		log.Printf("unknown sine transition %q in %s, sliding", config.Transition, path)
+		config.Transition = SineSlide
+	}
+	if config.Scroll <= 0 {
+		config.Scroll = defaultSineConfig.Scroll
+	}
+	if config.Morph <= 0 {
+		config.Morph = defaultSineConfig.Morph
+	}
+	if len(config.Animations) == 0 {
+		config.Animations = defaultSineConfig.Animations
+	}
+	if config.GlyphWidth <= 0 || config.GlyphHeight <= 0 {
+		config.GlyphWidth, config.GlyphHeight = defaultSineConfig.GlyphWidth, defaultSineConfig.GlyphHeight
+	}
+	if len(config.Tiles) == 0 {
+		config.Tiles = defaultSineConfig.Tiles
+	}
+	return config
+}
+
+// sineLetter is a letter's place in the row, counted in carebear widths
+// from the first letter, and the place of the middle of the row.
+type sineLetter struct {
+	pos, mid float64
+}
+
+// sineRow places letters of the given widths, in carebear widths, along
+// the row. Neighbours are half their widths added up apart, so narrow
+// letters sit closer, and rows longer than the carebear letters are
+// squeezed to fit where they were.
+func sineRow(widths []float64) []sineLetter {
+	letters := make([]sineLetter, len(widths))
+	pos := 0.0
+	for i, w := range widths {
+		if i > 0 {
+			pos += (widths[i-1] + w) / 2
+		}
+		letters[i].pos = pos
+	}
+	scale := 1.0
+	if pos > sineSpan {
+		scale = sineSpan / pos
+	}
+	for i := range letters {
+		letters[i] = sineLetter{pos: letters[i].pos * scale, mid: pos * scale / 2}
+	}
+	return letters
+}
+
+func (w SineWave) at(t float64, l sineLetter) float64 {
+	amp := w.Amp
+	if amp == 0 {
+		amp = 1
+	}
+	switch w.Func {
+	case "spread":
+		return (l.pos - l.mid) * amp
+	case "cos":
+		return math.Cos((t-l.pos*w.Delay)*w.Speed) * amp
+	default:
+		return math.Sin((t-l.pos*w.Delay)*w.Speed) * amp
+	}
+}
+
+func sumWaves(waves []SineWave, t float64, l sineLetter) float64 {
+	sum := 0.0
+	for _, w := range waves {
+		sum += w.at(t, l)
+	}
+	return sum
+}
+
+// at returns the position of a letter along the axis, from the middle of
+// the pattern. size is half its width or height.
+func (a SineAxis) at(t float64, l sineLetter, size float64) float64 {
+	v := sumWaves(a.Waves, t, l) * size * a.Size
+	v += sumWaves(a.Wobble, t, l)
+	for _, twist := range a.Twists {
+		v *= twist.Bias + sumWaves(twist.Waves, t, l)
+	}
+	if a.Scale != 0 {
+		v *= a.Scale
+	}
+	return v
+}
+
+func (a SineAnimation) point(t float64, l sineLetter, width, height float64) sinePoint {
+	return sinePoint{x: a.X.at(t, l, width), y: a.Y.at(t, l, height)}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSineRow(t *testing.T) {
+	ones := func(n int) []float64 {
+		widths := make([]float64, n)
+		for i := range widths {
+			widths[i] = 1
+		}
+		return widths
+	}
+	carebears := make([]sineLetter, 12)
+	for i := range carebears {
+		carebears[i] = sineLetter{pos: float64(i), mid: 5.5}
+	}
+	squeezed := make([]sineLetter, 23)
+	for i := range squeezed {
+		squeezed[i] = sineLetter{pos: float64(i) / 2, mid: 5.5}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		widths []float64
+		want   []sineLetter
+	}{
+		{"carebears", ones(12), carebears},
+		{"one letter", []float64{0.5}, []sineLetter{{}}},
+		{"mixed widths", []float64{1, 0.5, 1}, []sineLetter{{0, 0.75}, {0.75, 0.75}, {1.5, 0.75}}},
+		{"long row", ones(23), squeezed},
+	} {
+		got := sineRow(tc.widths)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: %d letters, want %d", tc.name, len(got), len(tc.want))
+		}
+		for i := range got {
+			if math.Abs(got[i].pos-tc.want[i].pos) > 1e-9 || math.Abs(got[i].mid-tc.want[i].mid) > 1e-9 {
+				t.Errorf("%s: letter %d at %+v, want %+v", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
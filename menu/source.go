@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+// MusicSource is a tune rendered as 16-bit stereo at the mixer's sample
+// rate. YMPlayer is the main one; the chip features it adds, like muting
+// voices or the register window, are reached by asserting to *YMPlayer.
+type MusicSource interface {
+	io.ReadSeeker
+	io.Closer
+	Info() SourceInfo
+	Position() time.Duration
+	Duration() time.Duration
+	SetLoop(loop bool)
+}
+
+// SourceInfo describes a tune whatever its format. Fields a format doesn't
+// store are left empty.
+type SourceInfo struct {
+	Title   string
+	Author  string
+	Comment string
+	// Format names the kind of file as its decoder reports it, such as
+	// "YM5!" or "WAV".
+	Format   string
+	Duration time.Duration
+	// LoopStart is where playback goes back to when a looping tune reaches
+	// its end.
+	LoopStart time.Duration
+}
+
+// musicFormat recognises a kind of music file from its contents.
+type musicFormat struct {
+	name       string
+	extensions []string
+	detect     func(data []byte) bool
+	open       func(name string, data []byte, sampleRate int, loop bool) (MusicSource, error)
+}
+
+// musicFormats are tried in order by OpenMusic.
+var musicFormats = []musicFormat{
+	{name: "YM", extensions: []string{".ym"}, detect: isYM, open: openYM},
+	{name: "WAV", extensions: []string{".wav"}, detect: isWAV, open: openWAV},
+}
+
+// OpenMusic plays data with the first format that recognises it. The name,
+// usually the file path, stands in for a title when the format has none.
+func OpenMusic(name string, data []byte, sampleRate int, loop bool) (MusicSource, error) {
+	for _, f := range musicFormats {
+		if f.detect(data) {
+			return f.open(name, data, sampleRate, loop)
+		}
+	}
+	return nil, fmt.Errorf("%s: unknown music format", name)
+}
+
+// isMusicFile reports whether a file name has the extension of a format.
+func isMusicFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range musicFormats {
+		for _, e := range f.extensions {
+			if ext == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isYM accepts raw YM files and the LHA archives most of them are packed
+// in, which stsound unpacks.
+func isYM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("YM")) || (len(data) >= 7 && string(data[2:7]) == "-lh5-")
+}
+
+func openYM(name string, data []byte, sampleRate int, loop bool) (MusicSource, error) {
+	return NewYMPlayer(data, sampleRate, loop)
+}
+
+func isWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+func openWAV(name string, data []byte, sampleRate int, loop bool) (MusicSource, error) {
+	stream, err := wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode WAV data: %w", err)
+	}
+	return NewPCMSource(stream, stream.Length(), sampleRate, loop, SourceInfo{
+		Title:    strings.ToUpper(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))),
+		Format:   "WAV",
+		Duration: samplesToDuration(stream.Length()/bytesPerSample, sampleRate),
+	}), nil
+}
+
+// PCMSource plays already decoded 16-bit stereo audio, looping back to the
+// start when asked to.
+type PCMSource struct {
+	mutex      sync.Mutex
+	stream     io.ReadSeeker
+	length     int64
+	sampleRate int
+	played     int64
+	loop       bool
+	info       SourceInfo
+	scope      *ScopeRing
+}
+
+func NewPCMSource(stream io.ReadSeeker, length int64, sampleRate int, loop bool, info SourceInfo) *PCMSource {
+	return &PCMSource{stream: stream, length: length, sampleRate: sampleRate, loop: loop, info: info}
+}
+
+func (s *PCMSource) Read(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p = p[:len(p)/bytesPerSample*bytesPerSample]
+	filled := 0
+	for filled < len(p) {
+		n, err := s.stream.Read(p[filled:])
+		filled += n
+		if err == io.EOF && s.loop && s.length > 0 {
+			if _, err := s.stream.Seek(0, io.SeekStart); err != nil {
+				return filled, err
+			}
+			continue
+		}
+		if err != nil {
+			s.account(p[:filled])
+			return filled, err
+		}
+	}
+	s.account(p)
+	return filled, nil
+}
+
+// account counts what Read returned and copies it to the scope.
+func (s *PCMSource) account(p []byte) {
+	s.played += int64(len(p) / bytesPerSample)
+	if s.scope == nil {
+		return
+	}
+	for i := 0; i+bytesPerSample <= len(p); i += bytesPerSample {
+		l := int16(p[i]) | int16(p[i+1])<<8
+		r := int16(p[i+2]) | int16(p[i+3])<<8
+		s.scope.push(int16((int32(l)+int32(r))/2), [ayVoices]int32{})
+	}
+}
+
+// Seek moves playback to a byte offset, rounded down to a whole sample.
+// Like Position, offsets are within one pass of the audio: looping sources
+// wrap past the end back to the start, others stop at the end.
+func (s *PCMSource) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.position() * bytesPerSample
+	case io.SeekEnd:
+		offset += s.length
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	offset = max(offset/bytesPerSample*bytesPerSample, 0)
+	if s.loop && s.length > 0 {
+		offset %= s.length
+	} else {
+		offset = min(offset, s.length)
+	}
+	pos, err := s.stream.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	s.played = pos / bytesPerSample
+	return pos, nil
+}
+
+func (s *PCMSource) Close() error {
+	return nil
+}
+
+func (s *PCMSource) Info() SourceInfo {
+	return s.info
+}
+
+// Position returns how much has been played. Like YMPlayer, looping
+// sources go back to the start at the end, so it stays within Duration.
+func (s *PCMSource) Position() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return samplesToDuration(s.position(), s.sampleRate)
+}
+
+// position returns the sample Position is at. The caller holds the mutex.
+func (s *PCMSource) position() int64 {
+	if samples := s.length / bytesPerSample; s.loop && samples > 0 {
+		return s.played % samples
+	}
+	return s.played
+}
+
+func (s *PCMSource) Duration() time.Duration {
+	return s.info.Duration
+}
+
+func (s *PCMSource) SetLoop(loop bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.played = s.position()
+	s.loop = loop
+}
+
+func (s *PCMSource) SetScope(ring *ScopeRing) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scope = ring
+}
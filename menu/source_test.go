@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestPCMSourceSeek(t *testing.T) {
+	const length = 100 * bytesPerSample
+	for _, tc := range []struct {
+		loop         bool
+		offset       int64
+		whence       int
+		before, want int64
+		wantErr      bool
+	}{
+		{offset: 40, whence: io.SeekStart, want: 40},
+		{offset: 6, whence: io.SeekStart, want: 4},
+		{offset: 0, whence: io.SeekCurrent, before: 20, want: 20},
+		{offset: -8, whence: io.SeekCurrent, before: 20, want: 12},
+		{offset: -8, whence: io.SeekCurrent, before: 4, want: 0},
+		{offset: -40, whence: io.SeekEnd, want: length - 40},
+		{offset: 40, whence: io.SeekEnd, want: length},
+		{loop: true, offset: 40, whence: io.SeekEnd, want: 40},
+		{loop: true, offset: 8, whence: io.SeekCurrent, before: length - 4, want: 4},
+		{offset: 0, whence: 7, wantErr: true},
+	} {
+		s := NewPCMSource(bytes.NewReader(make([]byte, length)), length, defaultSampleRate, tc.loop, SourceInfo{})
+		if _, err := s.Seek(tc.before, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.Seek(tc.offset, tc.whence)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Seek(%d, %d) gave no error", tc.offset, tc.whence)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("loop %v, at %d: Seek(%d, %d) = %d, %v, want %d",
+				tc.loop, tc.before, tc.offset, tc.whence, got, err, tc.want)
+		}
+	}
+}
+
+// TestPCMSourceLoopingPosition checks that a looping source counts its
+// position within one pass, and that Seek reports the same place.
+func TestPCMSourceLoopingPosition(t *testing.T) {
+	const length = 100 * bytesPerSample
+	s := NewPCMSource(bytes.NewReader(make([]byte, length)), length, defaultSampleRate, true, SourceInfo{})
+	if _, err := s.Read(make([]byte, 130*bytesPerSample)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Position(), samplesToDuration(30, defaultSampleRate); got != want {
+		t.Errorf("position %s, want %s", got, want)
+	}
+	if got, _ := s.Seek(0, io.SeekCurrent); got != 30*bytesPerSample {
+		t.Errorf("Seek(0, SeekCurrent) = %d, want %d", got, 30*bytesPerSample)
+	}
+}
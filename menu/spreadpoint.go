@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	spreadPointCount    = 640
+	spreadPointHold     = 5.0
+	spreadPointMorph    = 2.0
+	spreadPointDotSizes = 4
+)
+
+type pointShape struct {
+	Name   string
+	Points []Vec3
+}
+
+type SpreadPointScreen struct {
+	shapes    []pointShape
+	current   int
+	next      int
+	morphTime float64
+	holdTime  float64
+	rotation  Rotation
+	camera    Camera
+	time      float64
+
+	morphed   []Vec3
+	projected []ProjectedPoint
+	dots      []*ebiten.Image
+}
+
+func newSpreadPointScreen(_ *Game) Screen {
+	logo := loadMask(screenAssetPath("SPREADPOINT", "logo.png"), makePlaceholderLogoMask)
+	s := &SpreadPointScreen{
+		shapes: []pointShape{
+			{Name: "SPHERE", Points: SpherePoints(spreadPointCount)},
+			{Name: "CUBE", Points: CubePoints(spreadPointCount)},
+			{Name: "TORUS", Points: TorusPoints(spreadPointCount, 0.7, 0.3)},
+			{Name: "LOGO", Points: MaskPoints(logo, spreadPointCount)},
+		},
+		next: 1,
+		camera: Camera{
+			Distance: 3.2,
+			Focal:    screenHeight * 1.1,
+			CenterX:  screenWidth / 2,
+			CenterY:  screenHeight / 2,
+		},
+		morphed:   make([]Vec3, 0, spreadPointCount),
+		projected: make([]ProjectedPoint, 0, spreadPointCount),
+	}
+	for i := 0; i < spreadPointDotSizes; i++ {
+		s.dots = append(s.dots, makeDot(i+1))
+	}
+	return s
+}
+
+func (s *SpreadPointScreen) Update() error {
+	const dt = 1.0 / 60.0
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) && s.morphTime == 0 {
+		s.holdTime = spreadPointHold
+	}
+
+	s.time += dt
+	s.rotation = Rotation{
+		X: s.time * 0.53,
+		Y: s.time * 0.71,
+		Z: math.Sin(s.time*0.3) * 0.4,
+	}
+
+	if s.morphTime > 0 {
+		s.morphTime += dt
+		if s.morphTime >= spreadPointMorph {
+			s.current = s.next
+			s.next = (s.next + 1) % len(s.shapes)
+			s.morphTime = 0
+			s.holdTime = 0
+		}
+		return nil
+	}
+	s.holdTime += dt
+	if s.holdTime >= spreadPointHold {
+		s.morphTime = dt
+	}
+	return nil
+}
+
+func (s *SpreadPointScreen) Draw(dst *ebiten.Image) {
+	points := s.shapes[s.current].Points
+	if s.morphTime > 0 {
+		s.morphed = MorphPoints(s.morphed, points, s.shapes[s.next].Points, s.morphTime/spreadPointMorph)
+		points = s.morphed
+	}
+	s.projected = TransformPoints(s.projected, points, s.rotation, s.camera)
+
+	// Dots further away are drawn smaller and darker.
+	nearest := s.camera.Distance - 1
+	farthest := s.camera.Distance + 1
+	var op ebiten.DrawImageOptions
+	for _, p := range s.projected {
+		near := 1 - (p.Depth-nearest)/(farthest-nearest)
+		near = math.Max(0, math.Min(1, near))
+		size := int(near * float64(len(s.dots)-1))
+		dot := s.dots[size]
+		half := float64(dot.Bounds().Dx()) / 2
+
+		op.GeoM.Reset()
+		op.GeoM.Translate(math.Floor(p.X-half), math.Floor(p.Y-half))
+		op.ColorScale.Reset()
+		op.ColorScale.Scale(float32(0.3+near*0.7), float32(0.3+near*0.7), float32(0.3+near*0.7), 1)
+		dst.DrawImage(dot, &op)
+	}
+
+	name := s.shapes[s.current].Name
+	if s.morphTime > 0 {
+		name += " > " + s.shapes[s.next].Name
+	}
+	ebitenutil.DebugPrintAt(dst, fmt.Sprintf("%s\nSPACE MORPH", name), 8, 8)
+}
+
+func makeDot(radius int) *ebiten.Image {
+	size := radius*2 + 1
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := float64(x - radius)
+			dy := float64(y - radius)
+			d := math.Sqrt(dx*dx+dy*dy) / (float64(radius) + 0.5)
+			if d > 1 {
+				continue
+			}
+			v := uint8(255 * (1 - d*0.6))
+			img.Set(x, y, color.RGBA{v, v, 255, 255})
+		}
+	}
+	return ebiten.NewImageFromImage(img)
+}
+
+var placeholderLogo = []string{
+	"#####  ####  ####",
+	"  #   #      #   #",
+	"  #   #      ####",
+	"  #   #      #   #",
+	"  #    ####  ####",
+}
+
+func makePlaceholderLogoMask() image.Image {
+	const scale = 4
+	width := 0
+	for _, row := range placeholderLogo {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	img := image.NewAlpha(image.Rect(0, 0, width*scale, len(placeholderLogo)*scale))
+	for y, row := range placeholderLogo {
+		for x, c := range row {
+			if c != '#' {
+				continue
+			}
+			for sy := 0; sy < scale; sy++ {
+				for sx := 0; sx < scale; sx++ {
+					img.SetAlpha(x*scale+sx, y*scale+sy, color.Alpha{A: 255})
+				}
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// defaultPatternFrames is the pattern length assumed for tunes, which
+	// don't record their patterns: 64 rows of one frame.
+	defaultPatternFrames = 64
+	// maxEventFrames caps the frames caught up in one update, so a stall
+	// doesn't replay seconds of events at once.
+	maxEventFrames = 25
+	// pulseDecay is how much of the pulse is left after one update.
+	pulseDecay = 0.85
+	// pulseAverage is how quickly Level follows the pulse.
+	pulseAverage = 0.05
+	// deltaSmoothing is how much of the measured step Delta takes on each
+	// update, and maxDelta the largest step it believes.
+	deltaSmoothing = 0.25
+	maxDelta       = 0.1
+)
+
+type MusicEventKind int
+
+const (
+	MusicFrame MusicEventKind = iota
+	MusicPattern
+	MusicNoteOn
+)
+
+// MusicEvent is sent for every frame of the tune heard, on pattern
+// boundaries and for each voice starting a note. Frame is the frame of the
+// YM file, which starts over from the loop frame when the tune loops.
+type MusicEvent struct {
+	Kind  MusicEventKind
+	Frame int
+	Voice int
+}
+
+// MusicClock follows the tune as it is heard rather than as it is rendered:
+// the position of the playing YMPlayer less what is still waiting in the
+// audio buffers. Visuals read Time, Delta and Pulse, or subscribe to events.
+type MusicClock struct {
+	// Time is how far into the tune the listener is, and Delta how much
+	// that moved since the last update, smoothed and never negative so
+	// animations driven by it don't stutter. Without music Delta is one
+	// tick.
+	Time    time.Duration
+	Delta   float64
+	Playing bool
+	// Pulse jumps to 1 on every note-on and pattern start and decays
+	// towards 0, for effects that should throb with the music. Level is
+	// its recent average, high while the music is busy.
+	Pulse         float64
+	Level         float64
+	PatternFrames int
+
+	tune      MusicSource
+	frames    frameSource
+	frame     int
+	listeners []*musicListener
+}
+
+type musicListener struct {
+	fn func(MusicEvent)
+}
+
+// frameSource is implemented by sources that know the frames and notes of
+// their tune. Other sources drive the clock without sending events.
+type frameSource interface {
+	StreamFrame(pos time.Duration) int
+	TuneFrame(frame int) int
+	NoteOns(frame int) Voices
+}
+
+// Subscribe calls fn for every event from the next update on, until the
+// returned function is called.
+func (c *MusicClock) Subscribe(fn func(MusicEvent)) (unsubscribe func()) {
+	l := &musicListener{fn: fn}
+	c.listeners = append(c.listeners, l)
+	return func() {
+		for i, other := range c.listeners {
+			if other == l {
+				c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Events reports whether the tune playing sends frame events. Tunes that
+// don't, like WAV files, only move Time.
+func (c *MusicClock) Events() bool {
+	return c.Playing && c.frames != nil
+}
+
+// update moves the clock to now, the position in tune the listener has
+// reached, sending the events of every frame passed since the last update.
+func (c *MusicClock) update(tune MusicSource, now time.Duration) {
+	c.Pulse *= pulseDecay
+	c.Level += (c.Pulse - c.Level) * pulseAverage
+	if tune == nil {
+		c.tune, c.Playing = nil, false
+		c.Delta = 1.0 / 60.0
+		return
+	}
+	step := (now - c.Time).Seconds()
+	if tune != c.tune || now < c.Time {
+		// A different tune, a seek back or the tune looping: start over
+		// without replaying, keeping the animations moving at the pace they
+		// had.
+		step = c.Delta
+		c.tune = tune
+		c.frames, _ = tune.(frameSource)
+		c.Time = now
+		if c.frames != nil {
+			c.frame = c.frames.StreamFrame(now) - 1
+		}
+	}
+	c.Delta += (min(step, maxDelta) - c.Delta) * deltaSmoothing
+	c.Time, c.Playing = now, true
+	if c.frames == nil {
+		return
+	}
+
+	frame := c.frames.StreamFrame(now)
+	c.frame = max(c.frame, frame-maxEventFrames)
+	for ; c.frame < frame; c.frame++ {
+		c.emit(c.frames.TuneFrame(c.frame + 1))
+	}
+}
+
+func (c *MusicClock) emit(frame int) {
+	c.send(MusicEvent{Kind: MusicFrame, Frame: frame})
+	patternFrames := c.PatternFrames
+	if patternFrames <= 0 {
+		patternFrames = defaultPatternFrames
+	}
+	if frame%patternFrames == 0 {
+		c.Pulse = 1
+		c.send(MusicEvent{Kind: MusicPattern, Frame: frame})
+	}
+	notes := c.frames.NoteOns(frame)
+	for voice := 0; voice < ayVoices; voice++ {
+		if notes.Has(voice) {
+			c.Pulse = 1
+			c.send(MusicEvent{Kind: MusicNoteOn, Frame: frame, Voice: voice})
+		}
+	}
+}
+
+func (c *MusicClock) send(e MusicEvent) {
+	for _, l := range c.listeners {
+		l.fn(e)
+	}
+}
+
+// Seconds returns Time as seconds, the unit the effects animate in.
+func (c *MusicClock) Seconds() float64 {
+	return c.Time.Seconds()
+}
+
+// heardTune returns the source of the tune the listener is hearing: a
+// door, screen or zone tune that has faded in, or else the menu music.
+func (g *Game) heardTune() MusicSource {
+	if g.areaMusic.Silent {
+		return nil
+	}
+	if t := g.areaMusic.find(g.areaMusic.Path); t != nil {
+		return t.source
+	}
+	return g.menuSource
+}
+
+// musicTiming returns how far the audio heard lags behind the mixer and,
+// when tune is given, how far into it the listener is. Everything is read
+// in one snapshot of the mixer, so the positions agree with each other;
+// ok is false when the audio thread was reading at the time, and the
+// frame should make do with what it measured last.
+func (g *Game) musicTiming(tune MusicSource) (latency, heard time.Duration, ok bool) {
+	if g.mixer == nil || g.audioPlayer == nil {
+		if tune != nil {
+			heard = tune.Position()
+		}
+		return 0, heard, true
+	}
+	ok = g.mixer.Snapshot(func(rendered time.Duration) {
+		latency = max(rendered-g.audioPlayer.Position(), 0)
+		if tune != nil {
+			heard = max(tune.Position()-latency, 0)
+		}
+	})
+	return latency, heard, ok
+}
+
+// audioLatency returns the latency measured by the last updateMusicClock.
+func (g *Game) audioLatency() time.Duration {
+	return g.latency
+}
+
+// updateMusicClock measures the latency and moves the clock, once a frame.
+func (g *Game) updateMusicClock() {
+	tune := g.heardTune()
+	latency, heard, ok := g.musicTiming(tune)
+	if !ok {
+		return
+	}
+	g.latency = latency
+	g.clock.update(tune, heard)
+}
+
+// musicBoost returns the extra scroller speed for the current pulse. Only
+// the pulse above its recent level counts, so accents speed the scroller
+// up while busy passages don't hold it at top speed.
+func (g *Game) musicBoost() int {
+	return int(math.Round(max(g.clock.Pulse-g.clock.Level, 0) * scrollBoost))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/olivierh59500/ym-player/pkg/lzh"
+)
+
+const (
+	ymAttrInterleaved = 1 << iota
+	ymAttrDrumSigned
+	ymAttrDrum4Bits
+)
+
+// YMFile gives access to the header and register stream of a YM file,
+// which stsound keeps to itself. Formats without per-frame register data
+// (MIX1 and the trackers) report zero frames.
+type YMFile struct {
+	Format     string
+	Frames     int
+	FrameRate  int
+	LoopFrame  int
+	Clock      int
+	Attributes uint32
+	DigiDrums  [][]byte
+
+	stream    []byte
+	registers int
+}
+
+// depackYM returns the raw YM data, decompressing LHA-packed files the same
+// way stsound does.
+func depackYM(data []byte) ([]byte, error) {
+	if !lzh.IsLZHCompressed(data) {
+		return data, nil
+	}
+	raw, err := lzh.Decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("LZH decompression failed: %w", err)
+	}
+	return raw, nil
+}
+
+func ParseYMFile(data []byte) (*YMFile, error) {
+	raw, err := depackYM(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 4 {
+		return nil, fmt.Errorf("YM data too small")
+	}
+
+	f := &YMFile{Format: string(raw[:4])}
+	switch f.Format {
+	case "YM2!", "YM3!", "YM3b":
+		f.FrameRate = ymFrameRate
+		f.Clock = ayClock
+		f.Attributes = ymAttrInterleaved
+		f.registers = 14
+		f.stream = raw[4:]
+		if f.Format == "YM3b" {
+			if len(raw) < 8 {
+				return nil, fmt.Errorf("YM3b data too small for its loop frame")
+			}
+			f.stream = raw[4 : len(raw)-4]
+			f.LoopFrame = int(binary.LittleEndian.Uint32(raw[len(raw)-4:]))
+		}
+		f.Frames = len(f.stream) / f.registers
+	case "YM5!", "YM6!":
+		if err := f.parseYM5(raw); err != nil {
+			return nil, err
+		}
+	case "MIX1", "YMT1", "YMT2":
+	default:
+		return nil, fmt.Errorf("unknown YM format %q", f.Format)
+	}
+	if f.Frames > 0 && f.LoopFrame >= f.Frames {
+		return nil, fmt.Errorf("loop frame %d is past the last frame %d", f.LoopFrame, f.Frames-1)
+	}
+	return f, nil
+}
+
+func (f *YMFile) parseYM5(raw []byte) error {
+	if len(raw) < 34 || string(raw[4:12]) != "LeOnArD!" {
+		return fmt.Errorf("invalid %s header", f.Format)
+	}
+	f.Frames = int(binary.BigEndian.Uint32(raw[12:16]))
+	f.Attributes = binary.BigEndian.Uint32(raw[16:20])
+	drums := int(binary.BigEndian.Uint16(raw[20:22]))
+	f.Clock = int(binary.BigEndian.Uint32(raw[22:26]))
+	f.FrameRate = int(binary.BigEndian.Uint16(raw[26:28]))
+	f.LoopFrame = int(binary.BigEndian.Uint32(raw[28:32]))
+	skip := int(binary.BigEndian.Uint16(raw[32:34]))
+
+	pos := 34 + skip
+	for i := 0; i < drums; i++ {
+		if pos+4 > len(raw) {
+			return fmt.Errorf("truncated digidrum %d", i)
+		}
+		size := int(binary.BigEndian.Uint32(raw[pos : pos+4]))
+		pos += 4
+		if pos+size > len(raw) {
+			return fmt.Errorf("truncated digidrum %d", i)
+		}
+		f.DigiDrums = append(f.DigiDrums, raw[pos:pos+size])
+		pos += size
+	}
+	// Song name, author and comment are NUL-terminated strings.
+	for i := 0; i < 3; i++ {
+		if pos > len(raw) {
+			break
+		}
+		end := bytes.IndexByte(raw[pos:], 0)
+		if end < 0 {
+			return fmt.Errorf("truncated song information")
+		}
+		pos += end + 1
+	}
+
+	f.registers = 16
+	if pos+f.Frames*f.registers > len(raw) {
+		return fmt.Errorf("truncated register stream")
+	}
+	f.stream = raw[pos : pos+f.Frames*f.registers]
+	return nil
+}
+
+// Register returns the value stored for one register in one frame.
+func (f *YMFile) Register(frame, reg int) byte {
+	if frame < 0 || frame >= f.Frames || reg < 0 || reg >= f.registers {
+		return 0
+	}
+	if f.Attributes&ymAttrInterleaved != 0 {
+		return f.stream[reg*f.Frames+frame]
+	}
+	return f.stream[frame*f.registers+reg]
+}
+
+// State returns the chip registers as of the given frame. Frames that leave
+// the envelope shape alone report the shape last written.
+func (f *YMFile) State(frame int) AYState {
+	var s AYState
+	s.Frame = frame
+	for reg := range s.Registers {
+		s.Registers[reg] = f.Register(frame, reg)
+	}
+	if w := f.lastEnvelopeWrite(frame); w >= 0 {
+		s.Registers[13] = f.Register(w, 13)
+	} else {
+		s.Registers[13] = 0
+	}
+	return s
+}
+
+// lastEnvelopeWrite returns the latest frame at or before frame that sets
+// the envelope shape, or -1 when no earlier frame does.
+func (f *YMFile) lastEnvelopeWrite(frame int) int {
+	for ; frame >= 0; frame-- {
+		if f.Register(frame, 13) != 0xff {
+			return frame
+		}
+	}
+	return -1
+}
+
+// NoteOns guesses which voices start a note in the given frame: a voice
+// that becomes audible, gets louder or has its envelope restarted. Period
+// changes alone are ignored, since arpeggios and vibrato change the period
+// every frame.
+func (f *YMFile) NoteOns(frame int) Voices {
+	var on Voices
+	for voice := 0; voice < ayVoices; voice++ {
+		if !f.audible(frame, voice) {
+			continue
+		}
+		volume := f.Register(frame, 8+voice)
+		envelope := volume&0x10 != 0
+		switch {
+		case !f.audible(frame-1, voice),
+			!envelope && volume&15 > f.Register(frame-1, 8+voice)&15,
+			envelope && f.Register(frame, 13) != 0xff:
+			on |= 1 << voice
+		}
+	}
+	return on
+}
+
+// audible reports whether a voice makes any sound in a frame.
+func (f *YMFile) audible(frame, voice int) bool {
+	if frame < 0 {
+		return false
+	}
+	mixer := f.Register(frame, 7)
+	if mixer&(1<<voice) != 0 && mixer&(8<<voice) != 0 {
+		return false
+	}
+	return f.Register(frame, 8+voice)&0x1f != 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// ym3b builds a YM3b file with the given number of frames and loop frame.
+func ym3b(frames, loop int) []byte {
+	data := append([]byte("YM3b"), make([]byte, frames*14)...)
+	return binary.LittleEndian.AppendUint32(data, uint32(loop))
+}
+
+func TestParseYM3b(t *testing.T) {
+	f, err := ParseYMFile(ym3b(10, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Frames != 10 || f.LoopFrame != 4 {
+		t.Errorf("got %d frames looping at %d, want 10 looping at 4", f.Frames, f.LoopFrame)
+	}
+}
+
+func TestParseYMFileRejects(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":              nil,
+		"short YM3b":         []byte("YM3b\x01\x02"),
+		"YM3b loop past end": ym3b(10, 10),
+		"unknown format":     []byte("YM9!...."),
+	} {
+		if _, err := ParseYMFile(data); err == nil {
+			t.Errorf("%s: parsed without an error", name)
+		}
+	}
+}